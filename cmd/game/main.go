@@ -1,19 +1,36 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal"
 	"github.com/liqmix/slaptrax/internal/assets"
 	"github.com/liqmix/slaptrax/internal/audio"
 	"github.com/liqmix/slaptrax/internal/cache"
 	"github.com/liqmix/slaptrax/internal/display"
+	"github.com/liqmix/slaptrax/internal/external"
 	"github.com/liqmix/slaptrax/internal/input"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/l"
 	"github.com/liqmix/slaptrax/internal/logger"
+	"github.com/liqmix/slaptrax/internal/state"
 	"github.com/liqmix/slaptrax/internal/user"
 )
 
 func main() {
+	server := flag.String("server", "", "server profile name or URL to play against")
+	profile := flag.String("profile", "", "player profile to play as, created if it doesn't exist")
+	replay := flag.String("replay", "", "replay file to watch on start")
+	autoplay := flag.Bool("autoplay", false, "start with autoplay on, toggled on song selection with F3")
+	jitter := flag.Int64("autoplay-jitter", 0, "most ms autoplay presses land off target")
+	missRate := flag.Float64("autoplay-miss", 0, "fraction of notes autoplay misses, 0 to 1")
+	flag.Parse()
+	external.SetServerOverride(*server)
+	external.SetPlayerOverride(*profile)
+	internal.SetStartingReplay(*replay)
+	state.SetAutoplay(*autoplay, judge.Humanize{Jitter: *jitter, MissRate: *missRate})
+
 	err := user.Init()
 	if err != nil {
 		logger.Warn("Failed to initialize user: %v", err)
@@ -34,6 +51,21 @@ func main() {
 	input.InitInput()
 	defer input.Close()
 
+	// Another player's settings take effect right away
+	user.OnProfileSwitch(func() {
+		audio.SetBGMVolume(user.S().BGMVolume)
+		audio.SetSFXVolume(user.S().SFXVolume)
+		audio.SetSongVolume(user.S().SongVolume)
+		input.SetTrackKeys(input.TrackKeyConfig(user.S().KeyConfig))
+		if user.S().Locale != assets.CurrentLocale() {
+			if err := assets.SetLocale(user.S().Locale); err != nil {
+				logger.Warn("Failed to set locale: %v", err)
+			}
+		}
+		cache.Clear()
+		cache.RequestLayoutReinit()
+	})
+
 	// Ebiten setup
 	ebiten.SetWindowSize(user.S().ScreenWidth, user.S().ScreenHeight)
 	ebiten.SetFullscreen(user.S().Fullscreen)
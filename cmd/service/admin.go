@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+var (
+	errScoreNotFound = errors.New("score not found")
+	errDuplicatePlay = errors.New("play already submitted")
+	errStoreNotEmpty = errors.New("database is not empty, restore with -force to replace it")
+)
+
+// adminCommand is a subcommand of the service binary that runs against the store.
+// Badger locks its directory while open, so the server has to be stopped first.
+type adminCommand struct {
+	usage string
+	run   func(args []string) (interface{}, error)
+}
+
+var adminCommands = map[string]adminCommand{
+	"users":      {"users [-q query]", adminUsers},
+	"ban":        {"ban -user name", adminBan},
+	"unban":      {"unban -user name", adminUnban},
+	"delete":     {"delete -score id", adminDeleteScore},
+	"invalidate": {"invalidate -score id", adminInvalidateScore},
+	"reindex":    {"reindex", adminReindex},
+	"backup":     {"backup -o file", adminBackup},
+	"restore":    {"restore -i file [-force]", adminRestore},
+	"recalc":     {"recalc", adminRecalculate},
+}
+
+// runAdmin executes a subcommand, printing its result or error as JSON
+func runAdmin(name string, args []string) int {
+	cmd, ok := adminCommands[name]
+	if !ok {
+		usage := make([]string, 0, len(adminCommands))
+		for _, c := range adminCommands {
+			usage = append(usage, c.usage)
+		}
+		sort.Strings(usage)
+		printJSON(map[string]interface{}{
+			"error":    fmt.Sprintf("unknown command %q", name),
+			"commands": usage,
+			"note":     "commands need the server stopped, the database can only be opened once",
+		})
+		return 2
+	}
+
+	result, err := cmd.run(args)
+	if err != nil {
+		printJSON(map[string]string{"error": err.Error()})
+		return 1
+	}
+
+	printJSON(result)
+	return 0
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func adminUsers(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("users", flag.ContinueOnError)
+	query := fs.String("q", "", "only users whose name contains this")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	users, err := store.SearchUsers(*query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i].Password = ""
+	}
+	return users, nil
+}
+
+func adminBan(args []string) (interface{}, error) {
+	return adminSetDeleted("ban", args, true)
+}
+
+func adminUnban(args []string) (interface{}, error) {
+	return adminSetDeleted("unban", args, false)
+}
+
+func adminSetDeleted(name string, args []string, deleted bool) (interface{}, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	username := fs.String("user", "", "username")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	user, err := store.GetUserByUsername(*username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", *username)
+	}
+
+	user, err = store.SetUserDeleted(user.ID, deleted)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+func adminDeleteScore(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	id := fs.Uint("score", 0, "score id")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return store.DeleteScore(uint(*id))
+}
+
+func adminInvalidateScore(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("invalidate", flag.ContinueOnError)
+	id := fs.Uint("score", 0, "score id")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return store.InvalidateScore(uint(*id))
+}
+
+func adminReindex(args []string) (interface{}, error) {
+	users, scores, err := store.RebuildIndexes()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"users": users, "scores": scores}, nil
+}
+
+func adminBackup(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	path := fs.String("o", "", "backup file")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *path == "" {
+		return nil, errors.New("missing backup file")
+	}
+
+	f, err := os.Create(*path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	version, err := store.Backup(f)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"file": *path, "version": version}, nil
+}
+
+func adminRestore(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	path := fs.String("i", "", "backup file")
+	force := fs.Bool("force", false, "wipe the database first when it isn't empty")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *path == "" {
+		return nil, errors.New("missing backup file")
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := store.Restore(f, *force); err != nil {
+		return nil, err
+	}
+	return map[string]string{"file": *path}, nil
+}
+
+func adminRecalculate(args []string) (interface{}, error) {
+	count, err := store.RecalculateRatings()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"users": count, "rating_version": RatingVersion}, nil
+}
+
+// SearchUsers lists every user whose username contains the query
+func (s *Store) SearchUsers(query string) ([]User, error) {
+	users := make([]User, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(userPrefix)
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix); it.Next() {
+			var user User
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &user)
+			})
+			if err != nil {
+				continue
+			}
+			if strings.Contains(strings.ToLower(user.Username), strings.ToLower(query)) {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SetUserDeleted soft-deletes or restores a user.
+// Deleted users are logged out, can't log in and are left off leaderboards.
+func (s *Store) SetUserDeleted(id uint, deleted bool) (*User, error) {
+	var user *User
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		user, err = s.getUserInTx(txn, id)
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			now := time.Now()
+			user.DeletedAt = &now
+			if err := s.deleteUserSessionsInTx(txn, id); err != nil {
+				return err
+			}
+		} else {
+			user.DeletedAt = nil
+		}
+		user.UpdatedAt = time.Now()
+
+		userData, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(userPrefix+fmt.Sprintf("%d", user.ID)), userData); err != nil {
+			return err
+		}
+
+		scores, err := s.getUserScoresInTx(txn, id)
+		if err != nil {
+			return err
+		}
+		charts := make(map[string]Score)
+		for _, score := range scores {
+			charts[fmt.Sprintf("%s:%d:%s", score.SongHash, score.Difficulty, judge.JudgementID(score.Judgement))] = score
+		}
+		for _, score := range charts {
+			if err := s.refreshLeaderboardInTx(txn, id, score.SongHash, score.Difficulty, score.Judgement, !deleted); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("user %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteScore removes a score along with its replay and index entries
+func (s *Store) DeleteScore(id uint) (*Score, error) {
+	return s.updateScore(id, func(txn *badger.Txn, score *Score) error {
+		if err := txn.Delete([]byte(scorePrefix + fmt.Sprintf("%d", score.ID))); err != nil {
+			return err
+		}
+		if err := txn.Delete([]byte(replayPrefix + fmt.Sprintf("%d", score.ID))); err != nil {
+			return err
+		}
+		if score.PlayID != "" {
+			if err := txn.Delete([]byte(fmt.Sprintf("%s%d:%s", playIndex, score.UserID, score.PlayID))); err != nil {
+				return err
+			}
+		}
+		return txn.Delete([]byte(fmt.Sprintf("%s%d:%d", userScoreIndex, score.UserID, score.ID)))
+	})
+}
+
+// InvalidateScore keeps a score on record but removes it from leaderboards and ratings
+func (s *Store) InvalidateScore(id uint) (*Score, error) {
+	return s.updateScore(id, func(txn *badger.Txn, score *Score) error {
+		score.Invalidated = true
+		score.Verified = false
+		score.PP = 0
+		score.UpdatedAt = time.Now()
+
+		scoreData, err := json.Marshal(score)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(scorePrefix+fmt.Sprintf("%d", score.ID)), scoreData)
+	})
+}
+
+// Helper method to change a score and then reindex the leaderboard entry and rating of its user
+func (s *Store) updateScore(id uint, update func(txn *badger.Txn, score *Score) error) (*Score, error) {
+	var score *Score
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		score, err = s.getScoreByID(txn, id)
+		if err != nil {
+			return err
+		}
+
+		if err := update(txn, score); err != nil {
+			return err
+		}
+
+		user, err := s.getUserInTx(txn, score.UserID)
+		if err != nil {
+			return err
+		}
+		if err := s.refreshLeaderboardInTx(txn, user.ID, score.SongHash, score.Difficulty, score.Judgement, user.DeletedAt == nil); err != nil {
+			return err
+		}
+		return s.updateRatingInTx(txn, user, false)
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, errScoreNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+// RebuildIndexes recreates the username, user score, play and leaderboard indexes
+// from the stored users and scores, returning how many of each were indexed
+func (s *Store) RebuildIndexes() (int, int, error) {
+	err := s.db.DropPrefix(
+		[]byte(usernameIndex),
+		[]byte(userScoreIndex),
+		[]byte(playIndex),
+		[]byte(leaderboardPrefix),
+		[]byte(leaderboardUserIndex),
+		[]byte(leaderboardCount),
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	deleted := make(map[uint]bool)
+	users, err := s.SearchUsers("")
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, user := range users {
+		deleted[user.ID] = user.DeletedAt != nil
+		err := s.db.Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(usernameIndex+user.Username), []byte(fmt.Sprintf("%d", user.ID)))
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var ids []uint
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(scorePrefix)
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix); it.Next() {
+			var id uint
+			if _, err := fmt.Sscanf(string(it.Item().Key()[len(opts.Prefix):]), "%d", &id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, id := range ids {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			score, err := s.getScoreByID(txn, id)
+			if err != nil {
+				return err
+			}
+
+			err = txn.Set([]byte(fmt.Sprintf("%s%d:%d", userScoreIndex, score.UserID, score.ID)), nil)
+			if err != nil {
+				return err
+			}
+
+			if score.PlayID != "" {
+				err = txn.Set([]byte(fmt.Sprintf("%s%d:%s", playIndex, score.UserID, score.PlayID)), []byte(fmt.Sprintf("%d", score.ID)))
+				if err != nil {
+					return err
+				}
+			}
+
+			if deleted[score.UserID] {
+				return nil
+			}
+			return s.updateLeaderboardInTx(txn, score)
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(leaderboardIndexBuilt), []byte(fmt.Sprintf("%d", leaderboardIndexVersion)))
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(users), len(ids), nil
+}
+
+// Backup streams a full backup of the database, it is safe to run while serving
+func (s *Store) Backup(w io.Writer) (uint64, error) {
+	return s.db.Backup(w, 0)
+}
+
+// Restore loads a backup made by Backup into an empty database.
+// Loading over existing data would mix the two, so force wipes it first.
+func (s *Store) Restore(r io.Reader, force bool) error {
+	empty, err := s.isEmpty()
+	if err != nil {
+		return err
+	}
+	if !empty {
+		if !force {
+			return errStoreNotEmpty
+		}
+		if err := s.db.DropAll(); err != nil {
+			return err
+		}
+	}
+	return s.db.Load(r, 256)
+}
+
+// isEmpty reports whether the database holds no keys at all
+func (s *Store) isEmpty() (bool, error) {
+	empty := true
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		it.Rewind()
+		empty = !it.Valid()
+		return nil
+	})
+	return empty, err
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	source := newTestStore(t)
+	newTestUser(t, source, "alice")
+	var backup bytes.Buffer
+	if _, err := source.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := newTestStore(t)
+	if err := empty.Restore(bytes.NewReader(backup.Bytes()), false); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := empty.GetUserByUsername("alice"); user == nil {
+		t.Fatal("backup not restored into an empty store")
+	}
+
+	s := newTestStore(t)
+	newTestUser(t, s, "bob")
+	if err := s.Restore(bytes.NewReader(backup.Bytes()), false); !errors.Is(err, errStoreNotEmpty) {
+		t.Fatalf("expected %v, got %v", errStoreNotEmpty, err)
+	}
+	if user, _ := s.GetUserByUsername("alice"); user != nil {
+		t.Fatal("backup loaded over existing data")
+	}
+
+	if err := s.Restore(bytes.NewReader(backup.Bytes()), true); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := s.GetUserByUsername("alice"); user == nil {
+		t.Fatal("backup not restored when forced")
+	}
+	if user, _ := s.GetUserByUsername("bob"); user != nil {
+		t.Fatal("existing data kept when forced")
+	}
+}
@@ -2,22 +2,22 @@ package main
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var (
-	jwtSecretKey   = []byte("itsmysecretandyoucanthaveit")
-	accessTokenTTL = 24 * time.Hour
-)
+var refreshTokenTTL = 30 * 24 * time.Hour
 
 type LoginCredentials struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device" binding:"max=64"`
 }
 
 type TokenPair struct {
@@ -26,38 +26,58 @@ type TokenPair struct {
 }
 
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userID uint) (string, error) {
+func GenerateAccessToken(userID uint, sessionID string) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(jwtKeys.AccessTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecretKey)
+	return jwtKeys.Sign(claims)
 }
 
-func GenerateRefreshToken() (string, error) {
+// GenerateRefreshToken returns a "<session id>.<secret>" token and the hash of its secret
+func GenerateRefreshToken(sessionID string) (string, string, error) {
 	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret := base64.URLEncoding.EncodeToString(b)
+	return sessionID + "." + secret, hashRefreshSecret(secret), nil
+}
+
+func parseRefreshToken(token string) (string, string, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", errors.New("malformed refresh token")
+	}
+	return id, secret, nil
+}
+
+// Secrets are random, so a plain hash is enough to keep them out of the database
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateSessionID() (string, error) {
+	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	return base64.URLEncoding.EncodeToString(b), nil
+	return hex.EncodeToString(b), nil
 }
 
 func ValidateAccessToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return jwtSecretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, jwtKeys.Keyfunc)
 
 	if err != nil {
 		return nil, err
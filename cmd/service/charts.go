@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/liqmix/slaptrax/internal/logger"
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+// Key prefixes for the chart registry
+const (
+	songPrefix     = "song:"
+	songDataPrefix = "song_data:"
+)
+
+const songTitleMaxLen = 128
+
+// Song is the registry entry for a song, the full chart data is stored under songDataPrefix
+type Song struct {
+	Hash         string    `json:"hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	UploadedBy   uint      `json:"uploaded_by"`
+	Title        string    `json:"title"`
+	Artist       string    `json:"artist"`
+	Album        string    `json:"album,omitempty"`
+	BPM          int       `json:"bpm"`
+	Duration     int64     `json:"duration,omitempty"`
+	ChartedBy    string    `json:"charted_by"`
+	Version      string    `json:"version"`
+	Difficulties []int     `json:"difficulties"`
+}
+
+func newSong(data *schema.SongDataV2) *Song {
+	difficulties := make([]int, 0, len(data.Charts))
+	for _, chart := range data.Charts {
+		difficulties = append(difficulties, chart.Difficulty)
+	}
+	sort.Ints(difficulties)
+
+	return &Song{
+		Hash:         data.Hash(),
+		Title:        data.Metadata.Title,
+		Artist:       data.Metadata.Artist,
+		Album:        data.Metadata.Album,
+		BPM:          data.Metadata.BPM,
+		Duration:     data.Metadata.Duration,
+		ChartedBy:    data.Metadata.ChartedBy,
+		Version:      data.Metadata.Version,
+		Difficulties: difficulties,
+	}
+}
+
+// CreateSong registers song data, returning the existing entry if the hash is already known
+func (s *Store) CreateSong(data *schema.SongDataV2, uploadedBy uint) (*Song, bool, error) {
+	if len(data.Metadata.Title) > songTitleMaxLen {
+		return nil, false, fmt.Errorf("title too long")
+	}
+
+	song := newSong(data)
+	song.CreatedAt = time.Now()
+	song.UploadedBy = uploadedBy
+
+	created := false
+	err := s.db.Update(func(txn *badger.Txn) error {
+		existing, err := s.getSongInTx(txn, song.Hash)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		if existing != nil {
+			song = existing
+			return nil
+		}
+
+		songData, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(songDataPrefix+song.Hash), songData); err != nil {
+			return err
+		}
+
+		meta, err := json.Marshal(song)
+		if err != nil {
+			return err
+		}
+		created = true
+		return txn.Set([]byte(songPrefix+song.Hash), meta)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return song, created, nil
+}
+
+// GetSong retrieves a registry entry by hash
+func (s *Store) GetSong(hash string) (*Song, error) {
+	var song *Song
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		song, err = s.getSongInTx(txn, hash)
+		return err
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return song, nil
+}
+
+// GetSongs lists every registered song, sorted by title
+func (s *Store) GetSongs() ([]Song, error) {
+	songs := make([]Song, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(songPrefix)
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix); it.Next() {
+			var song Song
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &song)
+			})
+			if err != nil {
+				continue
+			}
+			songs = append(songs, song)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(songs, func(i, j int) bool {
+		return songs[i].Title < songs[j].Title
+	})
+	return songs, nil
+}
+
+// GetSongData retrieves the full chart data of a song
+func (s *Store) GetSongData(hash string) (*schema.SongDataV2, error) {
+	var data schema.SongDataV2
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(songDataPrefix + hash))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &data)
+		})
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// ImportSongs registers every song.json found under the given directory
+func (s *Store) ImportSongs(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "song.json" {
+			return nil
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		data, err := schema.FromJSON(raw)
+		if err != nil {
+			logger.Warn("Skipping song %s: %v", path, err)
+			return nil
+		}
+
+		song, created, err := s.CreateSong(data, 0)
+		if err != nil {
+			return err
+		}
+		if created {
+			logger.Info("Imported song %s with hash %s", song.Title, song.Hash)
+		}
+		return nil
+	})
+}
+
+// Helper function to get a song within a transaction
+func (s *Store) getSongInTx(txn *badger.Txn, hash string) (*Song, error) {
+	item, err := txn.Get([]byte(songPrefix + hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var song Song
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &song)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &song, nil
+}
@@ -1,28 +1,20 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/gin-gonic/gin"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/logger"
+	"github.com/liqmix/slaptrax/internal/types/schema"
 )
 
-func getClientIP(c *gin.Context) string {
-	forwardedFor := c.GetHeader("X-Forwarded-For")
-	if forwardedFor != "" {
-		ips := strings.Split(forwardedFor, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-	return c.ClientIP()
-}
-
 func register(c *gin.Context) {
 	var input struct {
 		Username string `json:"username" binding:"required,min=1,max=8"`
@@ -53,6 +45,16 @@ func register(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"id": user.ID, "username": user.Username})
 }
 
+// unknownUser is checked in place of users that don't exist,
+// so they take as long to turn away as a wrong password
+var unknownUser = sync.OnceValue(func() *User {
+	user := &User{}
+	if err := user.SetPassword("unknown"); err != nil {
+		logger.Error("Failed to hash the unknown user password: %v", err)
+	}
+	return user
+})
+
 func login(c *gin.Context) {
 	var creds LoginCredentials
 	if err := c.ShouldBindJSON(&creds); err != nil {
@@ -60,41 +62,58 @@ func login(c *gin.Context) {
 		return
 	}
 
+	// Checked first and for any username, so lockouts don't tell which accounts exist
+	if wait := limits.Lockouts.Check(creds.Username); wait > 0 {
+		tooManyRequests(c, wait, "Account temporarily locked")
+		return
+	}
+
 	user, err := store.GetUserByUsername(creds.Username)
-	if err != nil || user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
 		return
 	}
 
-	if err := user.CheckPassword(creds.Password); err != nil {
+	// Unknown users get the same answer as a wrong password
+	known := user != nil
+	if !known {
+		user = unknownUser()
+	}
+	if err := user.CheckPassword(creds.Password); err != nil || !known {
+		if wait := limits.Lockouts.Fail(creds.Username); wait > 0 {
+			logger.Warn("Locked %s after failed logins from %s", creds.Username, c.ClientIP())
+			tooManyRequests(c, wait, "Account temporarily locked")
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	limits.Lockouts.Reset(user.Username)
+
+	if user.DeletedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+		return
+	}
 
 	// Update IP and login time
-	clientIP := getClientIP(c)
+	clientIP := c.ClientIP()
 	user.LastIP = clientIP
 	user.LastLoginAt = time.Now()
 
-	accessToken, err := GenerateAccessToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+	if err := store.UpdateUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
-	refreshToken, err := GenerateRefreshToken()
+	session, refreshToken, err := store.CreateSession(user.ID, creds.Device, clientIP)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
-	if err := user.SetRefreshToken(refreshToken); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
-	if err := store.UpdateUser(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	accessToken, err := GenerateAccessToken(user.ID, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
 		return
 	}
 
@@ -114,74 +133,43 @@ func refresh(c *gin.Context) {
 		return
 	}
 
-	// Find user with non-empty refresh token
-	var users []User
-	err := store.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-
-		prefix := []byte(userPrefix)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			var user User
-			err := it.Item().Value(func(v []byte) error {
-				return json.Unmarshal(v, &user)
-			})
-			if err != nil {
-				continue
-			}
-			if user.RefreshToken != "" {
-				users = append(users, user)
-			}
-		}
-		return nil
-	})
-
-	if err != nil || len(users) == 0 {
+	session, refreshToken, err := store.RotateSession(input.RefreshToken, c.ClientIP())
+	switch err {
+	case nil:
+	case errSessionReused:
+		logger.Warn("Refresh token reused from %s, session revoked", c.ClientIP())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
-	}
-
-	// Find user with matching refresh token
-	var validUser *User
-	for _, user := range users {
-		if err := user.CheckRefreshToken(input.RefreshToken); err == nil {
-			validUser = &user
-			break
-		}
-	}
-
-	if validUser == nil {
+	case errSessionNotFound:
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
 	}
 
-	// Generate new tokens
-	accessToken, err := GenerateAccessToken(validUser.ID)
+	accessToken, err := GenerateAccessToken(session.UserID, session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
 		return
 	}
 
-	refreshToken, err := GenerateRefreshToken()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
+	c.JSON(http.StatusOK, TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
 
-	if err := validUser.SetRefreshToken(refreshToken); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		return
-	}
+func logout(c *gin.Context) {
+	id := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(string)
 
-	if err := store.UpdateUser(validUser); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	if err := store.DeleteSession(id, sessionID); err != nil && err != errSessionNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	})
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
 func authMiddleware() gin.HandlerFunc {
@@ -200,11 +188,46 @@ func authMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Access tokens die with the session they were issued for
+		session, err := store.GetSession(claims.SessionID)
+		if err != nil || session == nil || session.UserID != claims.UserID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
 		c.Next()
 	}
 }
 
+func adminMiddleware() gin.HandlerFunc {
+	admins := make(map[string]bool)
+	for _, username := range strings.Split(os.Getenv("ADMIN_USERNAMES"), ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			admins[username] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		id := c.MustGet("userID").(uint)
+
+		user, err := store.GetUserByID(id)
+		if err != nil || user == nil || !admins[user.Username] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func getPublicKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": jwtKeys.PublicKeys()})
+}
+
 func getUser(c *gin.Context) {
 	id := c.MustGet("userID").(uint)
 
@@ -217,31 +240,76 @@ func getUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// func updateUser(c *gin.Context) {
-// 	id := c.MustGet("userID").(uint)
+func getUserSessions(c *gin.Context) {
+	id := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(string)
 
-// 	user, err := store.GetUserByID(id)
-// 	if err != nil || user == nil {
-// 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-// 		return
-// 	}
+	sessions, err := store.GetUserSessions(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-// 	// var settings external.Settings
-// 	// if err := c.ShouldBindJSON(&settings); err != nil {
-// 	// 	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-// 	// 	return
-// 	// }
+	for i := range sessions {
+		sessions[i].Clean()
+		sessions[i].Current = sessions[i].ID == sessionID
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func revokeUserSession(c *gin.Context) {
+	id := c.MustGet("userID").(uint)
+
+	err := store.DeleteSession(id, c.Param("id"))
+	if err == errSessionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
 
-// 	// user.Settings = settings
-// 	user.UpdatedAt = time.Now()
+func getSettings(c *gin.Context) {
+	id := c.MustGet("userID").(uint)
 
-// 	if err := store.UpdateUser(user); err != nil {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-// 		return
-// 	}
-// 	user.Clean()
-// 	c.JSON(http.StatusOK, user)
-// }
+	settings, err := store.GetUserSettings(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func updateSettings(c *gin.Context) {
+	id := c.MustGet("userID").(uint)
+
+	var settings map[string]SettingValue
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateSettings(settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merged, err := store.MergeUserSettings(id, settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, merged)
+}
+
+// Largest score submission read, replays at their event limit fit with room to spare
+const maxScoreBody = 4 << 20
 
 func createScore(c *gin.Context) {
 	id := c.MustGet("userID").(uint)
@@ -253,34 +321,71 @@ func createScore(c *gin.Context) {
 	}
 
 	var score Score
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxScoreBody)
 	if err := c.ShouldBindJSON(&score); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get current user score for this song/difficulty
-	currentScores, err := store.GetUserScores(id)
+	// A retried submission is recognised by its key like by its play id
+	if score.PlayID == "" {
+		score.PlayID = c.GetHeader("Idempotency-Key")
+		if len(score.PlayID) > 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency key too long"})
+			return
+		}
+	}
+
+	data, err := store.GetSongData(score.SongHash)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	var chart *schema.ChartDataV2
+	if data != nil {
+		chart = data.GetChart(score.Difficulty)
+	}
+	if chart == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown song or difficulty"})
+		return
+	}
 
-	ratingIncrease := getRatingValue(&score)
-	for _, currentScore := range currentScores {
-		if currentScore.SongHash == score.SongHash && currentScore.Difficulty == score.Difficulty {
-			value := getRatingValue(&currentScore)
-			ratingIncrease -= value
-			break
+	// Re-simulate the uploaded inputs, only verified scores count towards rank
+	replay := score.Replay
+	score.Replay = nil
+	score.Verified = false
+	score.Judgement, err = scoreJudgement(&score, replay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := scoreMods(&score, replay); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := verifyScore(&score, data.Metadata.BPM, chart, replay); err != nil {
+		switch err {
+		case errUnverifiable:
+			logger.Info("Storing score without replay for user %d on %s", id, score.SongHash)
+		case errScoreMismatch:
+			logger.Warn("Rejected score %d from user %d on %s", score.Score, id, score.SongHash)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 	}
 
-	if ratingIncrease < 0 {
-		ratingIncrease = 0
-	}
 	score.UserID = id
 	score.Username = user.Username
+	score.PP = getPerformanceValue(&score)
 
-	if err := store.CreateScoreAndUpdateRating(&score, id, ratingIncrease); err != nil {
+	if err := store.CreateScoreAndUpdateRating(&score, replay, id); err != nil {
+		if err == errDuplicatePlay {
+			c.JSON(http.StatusOK, score)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -309,29 +414,149 @@ func getUserScores(c *gin.Context) {
 }
 
 func getLeaderboard(c *gin.Context) {
+	song, difficulty, judgement, ok := bindChartQuery(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLeaderboardLimit)))
+	if err != nil || limit < 1 || limit > maxLeaderboardLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	page, err := store.GetLeaderboard(song, difficulty, judgement, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func getLeaderboardAround(c *gin.Context) {
+	id := c.MustGet("userID").(uint)
+
+	song, difficulty, judgement, ok := bindChartQuery(c)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(c.DefaultQuery("range", "5"))
+	if err != nil || n < 0 || n > maxLeaderboardRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid range"})
+		return
+	}
+
+	page, err := store.GetLeaderboardAround(song, difficulty, judgement, id, n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// bindChartQuery reads the song, difficulty and judgement profile query params, responding on failure.
+// Leaderboards without a judgement are the Standard ones.
+func bindChartQuery(c *gin.Context) (string, int, string, bool) {
 	song := c.Query("song")
-	difficultyStr := c.Query("difficulty")
+	if song == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song"})
+		return "", 0, "", false
+	}
 
-	var difficulty int
-	_, err := fmt.Sscanf(difficultyStr, "%d", &difficulty)
+	difficulty, err := strconv.Atoi(c.Query("difficulty"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid difficulty"})
+		return "", 0, "", false
+	}
+
+	judgement := judge.JudgementID(c.Query("judgement"))
+	if len(judgement) > 64 || strings.Contains(judgement, ":") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid judgement"})
+		return "", 0, "", false
+	}
+	return song, difficulty, judgement, true
+}
+
+func getSongs(c *gin.Context) {
+	songs, err := store.GetSongs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	scores, err := store.GetLeaderboard(song, difficulty)
+	c.JSON(http.StatusOK, songs)
+}
+
+func getSong(c *gin.Context) {
+	hash := c.Param("hash")
+
+	data, err := store.GetSongData(hash)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if data == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+		return
+	}
 
-	for _, score := range scores {
-		user, err := store.GetUserByID(score.UserID)
-		if err != nil {
-			continue
-		}
-		score.Rank = user.Rank
+	c.JSON(http.StatusOK, data)
+}
+
+func createSong(c *gin.Context) {
+	id := c.MustGet("userID").(uint)
+
+	var data schema.SongDataV2
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, scores)
+	if err := data.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	song, created, err := store.CreateSong(&data, id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, song)
+}
+
+func recalculateRatings(c *gin.Context) {
+	count, err := store.RecalculateRatings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": count, "rating_version": RatingVersion})
+}
+
+// backupStore streams an online backup of the database
+func backupStore(c *gin.Context) {
+	filename := fmt.Sprintf("slaptrax-%s.bak", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	if _, err := store.Backup(c.Writer); err != nil {
+		logger.Error("Backup failed: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
 }
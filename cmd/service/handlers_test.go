@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/liqmix/slaptrax/internal/judge"
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+// testSong has a single chart of three taps at difficulty 5
+func testSong() *schema.SongDataV2 {
+	tap := func(time int64) schema.NoteData {
+		return schema.NoteData{Time: time, Type: schema.NoteTypeTap}
+	}
+	return &schema.SongDataV2{
+		Version:  2,
+		Metadata: schema.SongMetadata{Title: "Slapped", Artist: "Tester", BPM: 120},
+		Charts: map[string]schema.ChartDataV2{
+			"normal": {Difficulty: 5, NoteCount: 3, Tracks: map[string][]schema.NoteData{
+				schema.TrackLeftBottom: {tap(1000), tap(1500)},
+				schema.TrackRightTop:   {tap(2000)},
+			}},
+		},
+	}
+}
+
+// perfectReplay presses every note of testSong on time
+func perfectReplay(song *schema.SongDataV2) *judge.Replay {
+	replay := judge.NewReplay(judge.Options{})
+	replay.Song = song.Hash()
+	replay.Difficulty = 5
+	for _, note := range []struct {
+		time  int64
+		track string
+	}{
+		{1000, schema.TrackLeftBottom},
+		{1500, schema.TrackLeftBottom},
+		{2000, schema.TrackRightTop},
+	} {
+		replay.Events = append(replay.Events,
+			judge.InputEvent{Time: note.time, Track: note.track, Pressed: true},
+			judge.InputEvent{Time: note.time + 50, Track: note.track, Pressed: false},
+		)
+	}
+	return replay
+}
+
+func TestCreateScore(t *testing.T) {
+	oldStore := store
+	t.Cleanup(func() { store = oldStore })
+
+	song := testSong()
+	store = newTestStore(t)
+	if _, _, err := store.CreateSong(song, 0); err != nil {
+		t.Fatal(err)
+	}
+	user := newTestUser(t, store, "slapper")
+
+	r := gin.New()
+	r.POST("/scores", func(c *gin.Context) {
+		c.Set("userID", user.ID)
+		createScore(c)
+	})
+
+	perfect := perfectReplay(song)
+	result, err := judge.Simulate(song.GetChart(5), song.Metadata.BPM, perfect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last note is never pressed
+	partial := *perfect
+	partial.Events = perfect.Events[:len(perfect.Events)-2]
+
+	// Played on another chart
+	other := *perfect
+	other.Difficulty = 4
+
+	// Pressed long after the chart ended
+	late := *perfect
+	late.Events = append([]judge.InputEvent{}, perfect.Events...)
+	late.Events = append(late.Events, judge.InputEvent{Time: 2e9, Track: schema.TrackLeftBottom, Pressed: true})
+
+	tests := []struct {
+		name     string
+		score    Score
+		status   int
+		verified bool
+	}{
+		{"verified", Score{Score: result.Total(), Replay: perfect}, http.StatusCreated, true},
+		{"within tolerance", Score{Score: result.Total() - scoreTolerance, Replay: perfect}, http.StatusCreated, true},
+		{"unverifiable", Score{Score: MaxScore}, http.StatusCreated, false},
+		{"mismatched", Score{Score: result.Total(), Replay: &partial}, http.StatusUnprocessableEntity, false},
+		{"replay of another chart", Score{Score: result.Total(), Replay: &other}, http.StatusUnprocessableEntity, false},
+		{"out of bounds replay", Score{Score: result.Total(), Replay: &late}, http.StatusBadRequest, false},
+		{"negative", Score{Score: -1}, http.StatusBadRequest, false},
+		{"over the max", Score{Score: MaxScore + 1}, http.StatusBadRequest, false},
+		{"unknown difficulty", Score{Score: result.Total(), Difficulty: 9, Replay: perfect}, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.score.SongHash = song.Hash()
+			if tt.score.Difficulty == 0 {
+				tt.score.Difficulty = 5
+			}
+
+			body, _ := json.Marshal(tt.score)
+			req := httptest.NewRequest(http.MethodPost, "/scores", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			var stored Score
+			if err := json.Unmarshal(w.Body.Bytes(), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Verified != tt.verified {
+				t.Errorf("expected verified %v, got %v", tt.verified, stored.Verified)
+			}
+			if tt.verified && stored.Score != result.Total() {
+				t.Errorf("expected the recomputed score %d, got %d", result.Total(), stored.Score)
+			}
+		})
+	}
+
+	// Only the verified plays reach the leaderboard
+	page, err := store.GetLeaderboard(song.Hash(), 5, judge.Standard.Name, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || !page.Entries[0].Verified {
+		t.Errorf("expected a single verified entry, got %+v", page.Entries)
+	}
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+	oldStore, oldLimits := store, limits
+	t.Cleanup(func() { store, limits = oldStore, oldLimits })
+
+	store = newTestStore(t)
+	newTestUser(t, store, "ann")
+	limits = &RateLimits{Lockouts: NewLockouts(2, time.Hour, nil)}
+
+	r := gin.New()
+	r.POST("/login", login)
+	post := func(username, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(LoginCredentials{Username: username, Password: password})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	wrong, unknown := post("ann", "wrong"), post("nobody", "wrong")
+	if wrong.Code != http.StatusUnauthorized || unknown.Code != wrong.Code || unknown.Body.String() != wrong.Body.String() {
+		t.Fatalf("expected unknown users to look like a wrong password, got %d %s and %d %s",
+			unknown.Code, unknown.Body, wrong.Code, wrong.Body)
+	}
+
+	// Unknown users are locked out like any other
+	if w := post("nobody", "wrong"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the unknown user to be locked, got %d", w.Code)
+	}
+	if w := post("nobody", "password"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the lockout to be checked first, got %d", w.Code)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
+// Token signing is configured through the environment:
+//
+//	JWT_KEYS_FILE   JSON key file, see KeyFile
+//	JWT_SECRET      single HS256 secret, used when there is no key file
+//	JWT_ACCESS_TTL  access token lifetime, e.g. "24h", overrides the key file
+//
+// Without either a random secret is generated, so tokens don't survive a restart.
+//
+// Keys are rotated by adding a new key, making it active and removing
+// the old one once every token it signed has expired.
+
+const defaultAccessTokenTTL = 24 * time.Hour
+
+// KeyFile is the format of JWT_KEYS_FILE
+type KeyFile struct {
+	Active    string      `json:"active"`     // ID of the key new tokens are signed with
+	AccessTTL string      `json:"access_ttl"` // Go duration, defaults to 24h
+	Keys      []KeyConfig `json:"keys"`
+}
+
+// KeyConfig is a single signing or verification key.
+// Paths are relative to the key file.
+type KeyConfig struct {
+	ID             string `json:"kid"`
+	Alg            string `json:"alg"` // HS256 or EdDSA
+	Secret         string `json:"secret,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"` // PKCS8 PEM
+	PublicKeyFile  string `json:"public_key_file,omitempty"`  // PKIX PEM, for verify only keys
+}
+
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{} // nil if the key can only verify
+	verify interface{}
+}
+
+// Keyring holds the keys tokens are signed and verified with
+type Keyring struct {
+	AccessTTL time.Duration
+
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var jwtKeys *Keyring
+
+// LoadKeys sets up jwtKeys from the environment
+func LoadKeys() error {
+	var err error
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		jwtKeys, err = loadKeyFile(path)
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		jwtKeys, err = newSecretKeyring([]byte(secret))
+	} else {
+		logger.Warn("No JWT keys configured, using a random secret")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		jwtKeys, err = newSecretKeyring(secret)
+	}
+	if err != nil {
+		return err
+	}
+
+	if ttl := os.Getenv("JWT_ACCESS_TTL"); ttl != "" {
+		jwtKeys.AccessTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("invalid JWT_ACCESS_TTL: %w", err)
+		}
+	}
+
+	logger.Info("Signing tokens with key %s (%s)", jwtKeys.active.id, jwtKeys.active.method.Alg())
+	return nil
+}
+
+func newSecretKeyring(secret []byte) (*Keyring, error) {
+	// Derive the ID so it stays the same across restarts
+	sum := sha256.Sum256(secret)
+	key, err := newKey(KeyConfig{
+		ID:     hex.EncodeToString(sum[:4]),
+		Alg:    jwt.SigningMethodHS256.Alg(),
+		Secret: string(secret),
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keyring{
+		AccessTTL: defaultAccessTokenTTL,
+		active:    key,
+		keys:      map[string]*signingKey{key.id: key},
+	}, nil
+}
+
+func loadKeyFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var file KeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	k := &Keyring{
+		AccessTTL: defaultAccessTokenTTL,
+		keys:      make(map[string]*signingKey),
+	}
+	if file.AccessTTL != "" {
+		k.AccessTTL, err = time.ParseDuration(file.AccessTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access_ttl: %w", err)
+		}
+	}
+
+	for _, cfg := range file.Keys {
+		key, err := newKey(cfg, filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", cfg.ID, err)
+		}
+		if _, exists := k.keys[key.id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.id)
+		}
+		k.keys[key.id] = key
+	}
+
+	k.active = k.keys[file.Active]
+	if k.active == nil {
+		return nil, fmt.Errorf("active key %q not found", file.Active)
+	}
+	if k.active.sign == nil {
+		return nil, fmt.Errorf("active key %q has no private key", file.Active)
+	}
+	return k, nil
+}
+
+func newKey(cfg KeyConfig, dir string) (*signingKey, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("missing kid")
+	}
+	key := &signingKey{id: cfg.ID}
+
+	switch cfg.Alg {
+	case jwt.SigningMethodHS256.Alg():
+		if len(cfg.Secret) < 32 {
+			return nil, errors.New("secret must be at least 32 bytes")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.sign = []byte(cfg.Secret)
+		key.verify = key.sign
+
+	case jwt.SigningMethodEdDSA.Alg():
+		key.method = jwt.SigningMethodEdDSA
+		if cfg.PrivateKeyFile != "" {
+			data, err := os.ReadFile(resolveKeyPath(dir, cfg.PrivateKeyFile))
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.sign = private
+			key.verify = private.(ed25519.PrivateKey).Public()
+		} else if cfg.PublicKeyFile != "" {
+			data, err := os.ReadFile(resolveKeyPath(dir, cfg.PublicKeyFile))
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseEdPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.verify = public
+		} else {
+			return nil, errors.New("missing private_key_file or public_key_file")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", cfg.Alg)
+	}
+	return key, nil
+}
+
+func resolveKeyPath(dir, path string) string {
+	if filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// Sign signs the claims with the active key, naming it in the kid header
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.sign)
+}
+
+// Keyfunc picks the verification key named by the token's kid header
+func (k *Keyring) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+	return key.verify, nil
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	X         string `json:"x"`
+}
+
+// PublicKeys lists the asymmetric keys other services can verify tokens with
+func (k *Keyring) PublicKeys() []JWK {
+	keys := make([]JWK, 0)
+	for _, key := range k.keys {
+		public, ok := key.verify.(ed25519.PublicKey)
+		if !ok {
+			continue
+		}
+		keys = append(keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			KeyID:     key.id,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+			X:         base64.RawURLEncoding.EncodeToString(public),
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].KeyID < keys[j].KeyID
+	})
+	return keys
+}
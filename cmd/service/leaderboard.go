@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/liqmix/slaptrax/internal/judge"
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
+// Key prefixes for the leaderboard index.
+// Entries sort by judgement profile, song, difficulty, inverted score and then time achieved,
+// so a prefix scan walks a chart's leaderboard from first place down.
+const (
+	leaderboardPrefix     = "lb:"
+	leaderboardUserIndex  = "lb_user:"
+	leaderboardCount      = "lb_count:"
+	leaderboardIndexBuilt = "meta:lb_index"
+)
+
+// Version of the leaderboard keys, bump it whenever they change so the index is rebuilt.
+// Version 2 split leaderboards by judgement profile, version 3 left out custom profiles,
+// version 4 counted entries per chart and stored who they belong to in them.
+const leaderboardIndexVersion = 4
+
+// Scores are inverted against this so higher scores sort first
+const leaderboardScoreCeiling = 1<<31 - 1
+
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+	maxLeaderboardRange     = 50
+)
+
+// LeaderboardEntry is a user's best score on a chart with its absolute position
+type LeaderboardEntry struct {
+	Position int `json:"position"`
+	Score
+}
+
+// LeaderboardPage is a slice of a chart leaderboard
+type LeaderboardPage struct {
+	Total    int                `json:"total"`
+	Offset   int                `json:"offset"`
+	Position int                `json:"position,omitempty"` // Requesting user, "around me" only
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+// leaderboardValue is stored in each index entry, so pages are listed without looking up users
+type leaderboardValue struct {
+	ScoreID  uint    `json:"score_id"`
+	Username string  `json:"username"`
+	Rank     float64 `json:"rank"` // Rating of the user, kept current by updateRatingInTx
+}
+
+func leaderboardChartPrefix(song string, difficulty int, judgement string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%d:", leaderboardPrefix, judge.JudgementID(judgement), song, difficulty))
+}
+
+func leaderboardKey(score *Score) []byte {
+	inverted := leaderboardScoreCeiling - score.Score
+	if inverted < 0 {
+		inverted = 0
+	}
+	return []byte(fmt.Sprintf("%s%010d:%020d:%d",
+		leaderboardChartPrefix(score.SongHash, score.Difficulty, score.Judgement),
+		inverted,
+		score.CreatedAt.UnixNano(),
+		score.UserID,
+	))
+}
+
+func leaderboardUserKey(song string, difficulty int, judgement string, userID uint) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%d:%d", leaderboardUserIndex, judge.JudgementID(judgement), song, difficulty, userID))
+}
+
+func leaderboardCountKey(song string, difficulty int, judgement string) []byte {
+	return []byte(fmt.Sprintf("%s%s:%s:%d", leaderboardCount, judge.JudgementID(judgement), song, difficulty))
+}
+
+// Helper function to read the number of entries on a chart within a transaction
+func (s *Store) getLeaderboardCountInTx(txn *badger.Txn, song string, difficulty int, judgement string) (int, error) {
+	item, err := txn.Get(leaderboardCountKey(song, difficulty, judgement))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = item.Value(func(val []byte) error {
+		_, err := fmt.Sscanf(string(val), "%d", &count)
+		return err
+	})
+	return count, err
+}
+
+// Helper function to add to the number of entries on a chart within a transaction
+func (s *Store) addLeaderboardCountInTx(txn *badger.Txn, song string, difficulty int, judgement string, delta int) error {
+	count, err := s.getLeaderboardCountInTx(txn, song, difficulty, judgement)
+	if err != nil {
+		return err
+	}
+	count += delta
+	if count <= 0 {
+		return txn.Delete(leaderboardCountKey(song, difficulty, judgement))
+	}
+	return txn.Set(leaderboardCountKey(song, difficulty, judgement), []byte(fmt.Sprintf("%d", count)))
+}
+
+// Helper function to keep the user's best score in the index within a transaction.
+// Only ranked scores are indexed, see Score.Ranked.
+func (s *Store) updateLeaderboardInTx(txn *badger.Txn, score *Score) error {
+	if !score.Ranked() {
+		return nil
+	}
+
+	userKey := leaderboardUserKey(score.SongHash, score.Difficulty, score.Judgement, score.UserID)
+
+	item, err := txn.Get(userKey)
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	if err == nil {
+		oldKey, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		best, err := s.getLeaderboardScoreInTx(txn, oldKey)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		if best != nil && best.Score >= score.Score {
+			return nil
+		}
+		if err := txn.Delete(oldKey); err != nil {
+			return err
+		}
+	} else if err := s.addLeaderboardCountInTx(txn, score.SongHash, score.Difficulty, score.Judgement, 1); err != nil {
+		return err
+	}
+
+	value := leaderboardValue{ScoreID: score.ID, Username: score.Username}
+	if user, err := s.getUserInTx(txn, score.UserID); err == nil {
+		value.Username, value.Rank = user.Username, user.Rank
+	}
+	valueData, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	key := leaderboardKey(score)
+	if err := txn.Set(key, valueData); err != nil {
+		return err
+	}
+	return txn.Set(userKey, key)
+}
+
+// Helper function to store the current rating of a user in their entries within a transaction
+func (s *Store) updateLeaderboardRankInTx(txn *badger.Txn, user *User, scores []Score) error {
+	for i := range scores {
+		if !scores[i].Ranked() {
+			continue
+		}
+
+		item, err := txn.Get(leaderboardUserKey(scores[i].SongHash, scores[i].Difficulty, scores[i].Judgement, user.ID))
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		value, err := s.getLeaderboardValueInTx(txn, key)
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// Every score of the chart leads to the same entry
+		if value.ScoreID != scores[i].ID || value.Rank == user.Rank {
+			continue
+		}
+
+		value.Username, value.Rank = user.Username, user.Rank
+		valueData, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(key, valueData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to reindex a user's entry on a chart within a transaction,
+// used when a score is removed or the user is banned or restored
+func (s *Store) refreshLeaderboardInTx(txn *badger.Txn, userID uint, song string, difficulty int, judgement string, include bool) error {
+	userKey := leaderboardUserKey(song, difficulty, judgement, userID)
+
+	item, err := txn.Get(userKey)
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	if err == nil {
+		oldKey, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := txn.Delete(oldKey); err != nil {
+			return err
+		}
+		if err := txn.Delete(userKey); err != nil {
+			return err
+		}
+		if err := s.addLeaderboardCountInTx(txn, song, difficulty, judgement, -1); err != nil {
+			return err
+		}
+	}
+
+	if !include {
+		return nil
+	}
+
+	scores, err := s.getUserScoresInTx(txn, userID)
+	if err != nil {
+		return err
+	}
+	for i := range scores {
+		if scores[i].SongHash != song || scores[i].Difficulty != difficulty ||
+			judge.JudgementID(scores[i].Judgement) != judge.JudgementID(judgement) {
+			continue
+		}
+		if err := s.updateLeaderboardInTx(txn, &scores[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildLeaderboardIndex indexes scores stored before the leaderboard index existed
+// or before its keys last changed, see leaderboardIndexVersion
+func (s *Store) BuildLeaderboardIndex() error {
+	version := 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(leaderboardIndexBuilt))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			_, err := fmt.Sscanf(string(val), "%d", &version)
+			return err
+		})
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	if version >= leaderboardIndexVersion {
+		return nil
+	}
+
+	// Keys of an older version are never read again
+	for _, prefix := range []string{leaderboardPrefix, leaderboardUserIndex, leaderboardCount} {
+		if err := s.db.DropPrefix([]byte(prefix)); err != nil {
+			return err
+		}
+	}
+
+	var ids []uint
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(scorePrefix)
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix); it.Next() {
+			var id uint
+			if _, err := fmt.Sscanf(string(it.Item().Key()[len(opts.Prefix):]), "%d", &id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			score, err := s.getScoreByID(txn, id)
+			if err != nil {
+				return err
+			}
+
+			// Deleted users are left off leaderboards, as in RebuildIndexes
+			user, err := s.getUserInTx(txn, score.UserID)
+			if err == badger.ErrKeyNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if user.DeletedAt != nil {
+				return nil
+			}
+			return s.updateLeaderboardInTx(txn, score)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Indexed %d scores for leaderboards", len(ids))
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(leaderboardIndexBuilt), []byte(fmt.Sprintf("%d", leaderboardIndexVersion)))
+	})
+}
+
+// GetLeaderboard returns up to limit entries of a chart leaderboard starting at offset
+func (s *Store) GetLeaderboard(song string, difficulty int, judgement string, offset, limit int) (*LeaderboardPage, error) {
+	page := &LeaderboardPage{Offset: offset}
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		page.Entries, page.Total, err = s.getLeaderboardRangeInTx(txn, song, difficulty, judgement, offset, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetLeaderboardAround returns the entries within n places of the user's best score.
+// Users without a score on the chart get an empty page.
+func (s *Store) GetLeaderboardAround(song string, difficulty int, judgement string, userID uint, n int) (*LeaderboardPage, error) {
+	page := &LeaderboardPage{Entries: make([]LeaderboardEntry, 0)}
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(leaderboardUserKey(song, difficulty, judgement, userID))
+		if err == badger.ErrKeyNotFound {
+			page.Total, err = s.getLeaderboardCountInTx(txn, song, difficulty, judgement)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		userKey, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		index, err := s.countLeaderboardInTx(txn, song, difficulty, judgement, userKey)
+		if err != nil {
+			return err
+		}
+		page.Position = index + 1
+
+		page.Offset = index - n
+		if page.Offset < 0 {
+			page.Offset = 0
+		}
+		page.Entries, page.Total, err = s.getLeaderboardRangeInTx(txn, song, difficulty, judgement, page.Offset, index-page.Offset+n+1)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Helper function to count the entries of a chart ahead of the given key
+func (s *Store) countLeaderboardInTx(txn *badger.Txn, song string, difficulty int, judgement string, stop []byte) (int, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = leaderboardChartPrefix(song, difficulty, judgement)
+	opts.PrefetchValues = false
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	count := 0
+	for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix); it.Next() {
+		if bytes.Equal(it.Item().Key(), stop) {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Helper function to load a range of a chart leaderboard along with its total size
+func (s *Store) getLeaderboardRangeInTx(txn *badger.Txn, song string, difficulty int, judgement string, offset, limit int) ([]LeaderboardEntry, int, error) {
+	total, err := s.getLeaderboardCountInTx(txn, song, difficulty, judgement)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = leaderboardChartPrefix(song, difficulty, judgement)
+	opts.PrefetchValues = false
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	entries := make([]LeaderboardEntry, 0, limit)
+	index := 0
+	for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix) && index < offset+limit; it.Next() {
+		index++
+		if index <= offset {
+			continue
+		}
+
+		value, err := s.getLeaderboardValueInTx(txn, it.Item().Key())
+		if err != nil {
+			return nil, 0, err
+		}
+		score, err := s.getScoreByID(txn, value.ScoreID)
+		if err != nil {
+			return nil, 0, err
+		}
+		score.Username, score.Rank = value.Username, value.Rank
+
+		entries = append(entries, LeaderboardEntry{
+			Position: index,
+			Score:    *score,
+		})
+	}
+	return entries, total, nil
+}
+
+// Helper function to read an index entry within a transaction
+func (s *Store) getLeaderboardValueInTx(txn *badger.Txn, key []byte) (*leaderboardValue, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var value leaderboardValue
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// Helper function to resolve an index entry to its score within a transaction
+func (s *Store) getLeaderboardScoreInTx(txn *badger.Txn, key []byte) (*Score, error) {
+	value, err := s.getLeaderboardValueInTx(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.getScoreByID(txn, value.ScoreID)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+const testSongHash = "song"
+
+// newTestLeaderboard creates a user per score, each with a verified play of the test chart.
+// The first user has the best score.
+func newTestLeaderboard(t *testing.T, s *Store, scores ...int) []*User {
+	t.Helper()
+	users := make([]*User, 0, len(scores))
+	for i, points := range scores {
+		user := newTestUser(t, s, fmt.Sprintf("user%d", i+1))
+		score := &Score{SongHash: testSongHash, Difficulty: 5, Score: points, Verified: true, Username: user.Username, Judgement: judge.Standard.Name}
+		score.PP = getPerformanceValue(score)
+		if err := s.CreateScoreAndUpdateRating(score, nil, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+// leaderboardUsers lists the usernames on the test chart from first place down,
+// checking the count kept with the index and the ratings stored in it
+func leaderboardUsers(t *testing.T, s *Store) []string {
+	t.Helper()
+	page, err := s.GetLeaderboard(testSongHash, 5, judge.Standard.Name, 0, maxLeaderboardLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != len(page.Entries) {
+		t.Errorf("expected a total of %d entries, got %d", len(page.Entries), page.Total)
+	}
+	names := make([]string, 0, len(page.Entries))
+	for _, e := range page.Entries {
+		names = append(names, e.Username)
+		if user, err := s.GetUserByID(e.UserID); err != nil || user.Rank != e.Rank {
+			t.Errorf("expected %s to show their rating, got %v", e.Username, e.Rank)
+		}
+	}
+	return names
+}
+
+func TestLeaderboardAround(t *testing.T) {
+	s := newTestStore(t)
+	users := newTestLeaderboard(t, s, 9000, 8000, 7000, 6000, 5000, 4000, 3000)
+	outsider := newTestUser(t, s, "outsider")
+
+	tests := []struct {
+		name      string
+		user      *User
+		n         int
+		position  int
+		offset    int
+		positions []int
+	}{
+		{"middle", users[3], 2, 4, 1, []int{2, 3, 4, 5, 6}},
+		{"first place", users[0], 2, 1, 0, []int{1, 2, 3}},
+		{"last place", users[6], 2, 7, 4, []int{5, 6, 7}},
+		{"only themselves", users[3], 0, 4, 3, []int{4}},
+		{"not on the leaderboard", outsider, 2, 0, 0, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.GetLeaderboardAround(testSongHash, 5, judge.Standard.Name, tt.user.ID, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != len(users) || page.Position != tt.position || page.Offset != tt.offset {
+				t.Errorf("expected %d entries, position %d and offset %d, got %d, %d and %d",
+					len(users), tt.position, tt.offset, page.Total, page.Position, page.Offset)
+			}
+
+			positions := make([]int, 0, len(page.Entries))
+			for _, e := range page.Entries {
+				positions = append(positions, e.Position)
+				if e.Position == tt.position && e.UserID != tt.user.ID {
+					t.Errorf("expected %s at position %d, got %s", tt.user.Username, e.Position, e.Username)
+				}
+			}
+			if !reflect.DeepEqual(positions, tt.positions) {
+				t.Errorf("expected positions %v, got %v", tt.positions, positions)
+			}
+		})
+	}
+}
+
+func TestLeaderboardBan(t *testing.T) {
+	s := newTestStore(t)
+	users := newTestLeaderboard(t, s, 9000, 8000, 7000)
+
+	if _, err := s.SetUserDeleted(users[0].ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := leaderboardUsers(t, s), []string{"user2", "user3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the banned user to be removed, got %v", got)
+	}
+
+	// Rebuilt indexes leave banned users off as well
+	if _, _, err := s.RebuildIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := leaderboardUsers(t, s), []string{"user2", "user3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected rebuilding to leave the banned user off, got %v", got)
+	}
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(leaderboardIndexBuilt))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BuildLeaderboardIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := leaderboardUsers(t, s), []string{"user2", "user3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected building the index to leave the banned user off, got %v", got)
+	}
+
+	if _, err := s.SetUserDeleted(users[0].ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := leaderboardUsers(t, s), []string{"user1", "user2", "user3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the unbanned user to be restored, got %v", got)
+	}
+}
+
+func TestRebuildIndexesVersion(t *testing.T) {
+	s := newTestStore(t)
+	newTestLeaderboard(t, s, 9000)
+
+	if _, _, err := s.RebuildIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	var version []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(leaderboardIndexBuilt))
+		if err != nil {
+			return err
+		}
+		version, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%d", leaderboardIndexVersion); string(version) != want {
+		t.Errorf("expected index version %s, got %s", want, version)
+	}
+}
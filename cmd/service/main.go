@@ -32,19 +32,69 @@ func main() {
 	var err error
 	store, err = NewStore(dbPath)
 	if err != nil {
-		log.Fatalf("Failed to initialize BadgerDB: %v", err)
+		// Badger locks the directory, admin commands can't run next to the server
+		log.Fatalf("Failed to initialize BadgerDB, stop the server before running admin commands: %v", err)
 	}
 	defer store.Close()
 
-	// Initialize router
+	// Admin subcommands run against the store instead of serving
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		code := runAdmin(os.Args[1], os.Args[2:])
+		store.Close()
+		os.Exit(code)
+	}
+
+	if err := LoadKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	if err := LoadRateLimits(store); err != nil {
+		log.Fatalf("Failed to load rate limits: %v", err)
+	}
+
+	if err := store.BuildLeaderboardIndex(); err != nil {
+		log.Fatalf("Failed to build leaderboard index: %v", err)
+	}
+
+	if err := store.RecalculateRatingsIfOutdated(); err != nil {
+		log.Fatalf("Failed to recalculate ratings: %v", err)
+	}
+
+	// Register any bundled charts that aren't known yet
+	chartsPath := os.Getenv("CHARTS_PATH")
+	if chartsPath == "" {
+		chartsPath = filepath.Join("data", "songs")
+	}
+	if err := store.ImportSongs(chartsPath); err != nil {
+		log.Fatalf("Failed to import charts: %v", err)
+	}
+
+	r := newRouter()
+	if err := r.SetTrustedProxies(TrustedProxies()); err != nil {
+		log.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+
+	// Start server
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8008"
+	}
+
+	log.Printf("Starting server on port %s", port)
+	if err := r.Run(":" + port); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// newRouter sets up the routes of the API, serving from store with limits applied
+func newRouter() *gin.Engine {
 	r := gin.Default()
-	r.SetTrustedProxies(nil)
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
@@ -63,13 +113,20 @@ func main() {
 			c.JSON(http.StatusOK, gin.H{"status": "ok"})
 		})
 
+		// Public keys for verifying access tokens elsewhere
+		v1.GET("/.well-known/jwks.json", getPublicKeys)
+
 		// Authentication routes
-		v1.POST("/register", register)
-		v1.POST("/login", login)
-		v1.POST("/refresh", refresh)
+		v1.POST("/register", rateLimitMiddleware(limits.Register, nil), register)
+		v1.POST("/login", rateLimitMiddleware(limits.IP, limits.Username), login)
+		v1.POST("/refresh", rateLimitMiddleware(limits.IP, nil), refresh)
 
 		// Public leaderboard access
 		v1.GET("/scores/leaderboard", getLeaderboard)
+
+		// Chart registry
+		v1.GET("/songs", getSongs)
+		v1.GET("/songs/:hash", getSong)
 	}
 
 	// Protected routes
@@ -78,21 +135,28 @@ func main() {
 	{
 		// User routes
 		protected.GET("/user", getUser)
-		// protected.POST("/users", updateUser)
+		protected.GET("/user/settings", getSettings)
+		protected.PUT("/user/settings", updateSettings)
 		protected.GET("/user/scores", getUserScores)
 
+		// Session routes
+		protected.POST("/logout", logout)
+		protected.GET("/user/sessions", getUserSessions)
+		protected.DELETE("/user/sessions/:id", revokeUserSession)
+
 		// Score routes
 		protected.POST("/scores", createScore)
+		protected.GET("/scores/leaderboard/around", getLeaderboardAround)
 	}
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8008"
+	// Admin routes
+	admin := protected.Group("")
+	admin.Use(adminMiddleware())
+	{
+		admin.POST("/songs", createSong)
+		admin.POST("/admin/ratings/recalculate", recalculateRatings)
+		admin.GET("/admin/backup", backupStore)
 	}
 
-	log.Printf("Starting server on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	return r
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestStore opens a quiet store in a temporary directory, closed when the test ends
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Store{db: db}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// newTestUser creates a user with the password "password"
+func newTestUser(t *testing.T, s *Store, username string) *User {
+	t.Helper()
+	user := &User{Username: username}
+	if err := user.SetPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/logger"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -18,21 +19,22 @@ type Store struct {
 
 // User model
 type User struct {
-	ID           uint       `json:"id"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
-	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
-	Username     string     `json:"username"`
-	Password     string     `json:"password"`
-	Rank         float64    `json:"rank"`
-	RefreshToken string     `json:"refresh_token"`
-	LastIP       string     `json:"last_ip"`
-	LastLoginAt  time.Time  `json:"last_login_at"`
+	ID          uint       `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Username    string     `json:"username"`
+	Password    string     `json:"password"`
+	Rank        float64    `json:"rank"`
+	LastIP      string     `json:"last_ip"`
+	LastLoginAt time.Time  `json:"last_login_at"`
+
+	// Formula version the rank was calculated with
+	RatingVersion int `json:"rating_version"`
 }
 
 func (u *User) Clean() {
 	u.Password = ""
-	u.RefreshToken = ""
 	u.LastIP = ""
 }
 
@@ -44,12 +46,31 @@ type Score struct {
 	UserID     uint      `json:"user_id"`
 	Username   string    `json:"username"`
 	SongHash   string    `json:"song_hash"`
-	Score      int       `json:"score"`
+	Score      int       `json:"score" binding:"min=0,max=100000"`
 	Rank       float64   `json:"rank"`
 	Accuracy   float64   `json:"accuracy"`
 	MaxCombo   int       `json:"max_combo"`
 	PlayedAt   time.Time `json:"played_at"`
 	Difficulty int       `json:"difficulty"`
+	Verified   bool      `json:"verified"`
+	PP         float64   `json:"pp"`
+
+	// ID of the judge.Profile the play was judged with, leaderboards are split by it
+	Judgement string `json:"judgement,omitempty" binding:"max=64"`
+
+	// Generated by the client for each play, so a play queued offline is only stored once
+	PlayID string `json:"play_id,omitempty" binding:"max=64"`
+
+	// Modifiers the play was started with. Unranked modifiers and custom judgement profiles
+	// keep it off leaderboards and ratings.
+	Mods     judge.Mods `json:"mods,omitempty"`
+	Unranked bool       `json:"unranked,omitempty"`
+
+	// Set by an admin, the score is kept but no longer counts
+	Invalidated bool `json:"invalidated,omitempty"`
+
+	// Input stream of the play, stored separately under replayPrefix
+	Replay *judge.Replay `json:"replay,omitempty"`
 }
 
 func NewStore(path string) (*Store, error) {
@@ -71,6 +92,8 @@ const (
 	scorePrefix    = "score:"
 	usernameIndex  = "username_index:"
 	userScoreIndex = "user_score:"
+	replayPrefix   = "replay:"
+	playIndex      = "play:"
 )
 
 // CreateUser creates a new user
@@ -204,15 +227,20 @@ func (s *Store) CreateScore(score *Score) error {
 		}
 
 		// Store user-score index
-		return txn.Set(
+		err = txn.Set(
 			[]byte(fmt.Sprintf("%s%d:%d", userScoreIndex, score.UserID, score.ID)),
 			nil,
 		)
+		if err != nil {
+			return err
+		}
+
+		return s.updateLeaderboardInTx(txn, score)
 	})
 }
 
-// In your Store struct, add a new method:
-func (s *Store) CreateScoreAndUpdateRating(score *Score, userID uint, ratingIncrease float64) error {
+// CreateScoreAndUpdateRating stores a score with its replay and recalculates the user's rating
+func (s *Store) CreateScoreAndUpdateRating(score *Score, replay *judge.Replay, userID uint) error {
 	return s.db.Update(func(txn *badger.Txn) error {
 		// First get the user
 		user, err := s.getUserInTx(txn, userID)
@@ -220,18 +248,15 @@ func (s *Store) CreateScoreAndUpdateRating(score *Score, userID uint, ratingIncr
 			return err
 		}
 
-		// Update user's rating if there's an increase
-		if ratingIncrease > 0 {
-			user.Rank += ratingIncrease
-			user.UpdatedAt = time.Now()
-
-			// Save updated user
-			userData, err := json.Marshal(user)
+		// A play that was already submitted returns the stored score
+		if score.PlayID != "" {
+			existing, err := s.getPlayInTx(txn, userID, score.PlayID)
 			if err != nil {
 				return err
 			}
-			if err := txn.Set([]byte(userPrefix+fmt.Sprintf("%d", user.ID)), userData); err != nil {
-				return err
+			if existing != nil {
+				*score = *existing
+				return errDuplicatePlay
 			}
 		}
 
@@ -258,14 +283,69 @@ func (s *Store) CreateScoreAndUpdateRating(score *Score, userID uint, ratingIncr
 			return err
 		}
 
+		// Store replay
+		if replay != nil {
+			replayData, err := json.Marshal(replay)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(replayPrefix+fmt.Sprintf("%d", score.ID)), replayData); err != nil {
+				return err
+			}
+		}
+
 		// Store user-score index
-		return txn.Set(
+		err = txn.Set(
 			[]byte(fmt.Sprintf("%s%d:%d", userScoreIndex, score.UserID, score.ID)),
 			nil,
 		)
+		if err != nil {
+			return err
+		}
+
+		if score.PlayID != "" {
+			err = txn.Set([]byte(fmt.Sprintf("%s%d:%s", playIndex, score.UserID, score.PlayID)), []byte(fmt.Sprintf("%d", score.ID)))
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := s.updateLeaderboardInTx(txn, score); err != nil {
+			return err
+		}
+
+		// Pending writes are visible to the transaction, so the new score is included
+		return s.updateRatingInTx(txn, user, false)
 	})
 }
 
+// Helper function to get the score stored for a play within a transaction, nil if there is none
+func (s *Store) getPlayInTx(txn *badger.Txn, userID uint, playID string) (*Score, error) {
+	item, err := txn.Get([]byte(fmt.Sprintf("%s%d:%s", playIndex, userID, playID)))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var id uint
+	err = item.Value(func(val []byte) error {
+		_, err := fmt.Sscanf(string(val), "%d", &id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := s.getScoreByID(txn, id)
+	if err == badger.ErrKeyNotFound {
+		// The score was deleted, the play can be submitted again
+		return nil, nil
+	}
+	return score, err
+}
+
 // Helper function to get user within a transaction
 func (s *Store) getUserInTx(txn *badger.Txn, id uint) (*User, error) {
 	item, err := txn.Get([]byte(userPrefix + fmt.Sprintf("%d", id)))
@@ -310,37 +390,28 @@ func (s *Store) getNextIDInTx(txn *badger.Txn, sequence string) (uint, error) {
 
 func (s *Store) GetUserScores(userID uint) ([]Score, error) {
 	var scores []Score
-	prefix := []byte(fmt.Sprintf("%s%d:", userScoreIndex, userID))
 
-	// Map to track highest score per song and difficulty
-	highestScores := make(map[string]Score) // key: "songHash:difficulty"
+	// Map to track highest score per song, difficulty and judgement profile
+	highestScores := make(map[string]Score) // key: "songHash:difficulty:judgement"
 
 	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.Prefix = prefix
-
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			key := it.Item().Key()
-			var scoreID uint
-			_, err := fmt.Sscanf(string(key[len(prefix):]), "%d", &scoreID)
-			if err != nil {
-				continue
-			}
+		all, err := s.getUserScoresInTx(txn, userID)
+		if err != nil {
+			return err
+		}
 
-			score, err := s.getScoreByID(txn, scoreID)
-			if err != nil {
+		for _, score := range all {
+			// Unranked plays are never a best
+			if score.Unranked {
 				continue
 			}
 
-			// Create composite key for song and difficulty
-			mapKey := fmt.Sprintf("%s:%d", score.SongHash, score.Difficulty)
+			// Create composite key for song, difficulty and judgement
+			mapKey := fmt.Sprintf("%s:%d:%s", score.SongHash, score.Difficulty, judge.JudgementID(score.Judgement))
 
-			// If this is the first score for this song/difficulty or if it's higher than existing score
+			// If this is the first score for this chart and judgement or if it's higher than existing score
 			if existing, exists := highestScores[mapKey]; !exists || score.Score > existing.Score {
-				highestScores[mapKey] = *score
+				highestScores[mapKey] = score
 			}
 		}
 		return nil
@@ -363,65 +434,36 @@ func (s *Store) GetUserScores(userID uint) ([]Score, error) {
 	return scores, nil
 }
 
-func (s *Store) GetLeaderboard(song string, difficulty int) ([]Score, error) {
+// Helper method to get every score of a user within a transaction
+func (s *Store) getUserScoresInTx(txn *badger.Txn, userID uint) ([]Score, error) {
 	var scores []Score
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.Prefix = []byte(scorePrefix)
-
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Seek(opts.Prefix); it.Valid(); it.Next() {
-			item := it.Item()
-			var score Score
-			err := item.Value(func(val []byte) error {
-				return json.Unmarshal(val, &score)
-			})
-			if err != nil {
-				continue
-			}
-			// Only include scores for the specified song and difficulty
-			if score.SongHash == song && score.Difficulty == difficulty {
-				scores = append(scores, score)
-			}
-		}
-		return nil
-	})
-
-	// Sort scores by score value in descending order
-	if err == nil {
-		sort.Slice(scores, func(i, j int) bool {
-			return scores[i].Score > scores[j].Score
-		})
+	prefix := []byte(fmt.Sprintf("%s%d:", userScoreIndex, userID))
 
-		// Keep only the best score per user
-		seen := make(map[uint]bool)
-		uniqueScores := make([]Score, 0)
-		for _, score := range scores {
-			if !seen[score.UserID] {
-				seen[score.UserID] = true
-				uniqueScores = append(uniqueScores, score)
-			}
-		}
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.PrefetchValues = false
 
-		// Limit to top 10 scores
-		if len(uniqueScores) > 10 {
-			uniqueScores = uniqueScores[:10]
+	it := txn.NewIterator(opts)
+	var ids []uint
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		var scoreID uint
+		_, err := fmt.Sscanf(string(it.Item().Key()[len(prefix):]), "%d", &scoreID)
+		if err != nil {
+			continue
 		}
-		scores = uniqueScores
+		ids = append(ids, scoreID)
 	}
+	// Read-write transactions only allow one open iterator
+	it.Close()
 
-	// for each score found, attach the user's current rank
-	for i := range scores {
-		user, err := s.GetUserByID(scores[i].UserID)
+	for _, id := range ids {
+		score, err := s.getScoreByID(txn, id)
 		if err != nil {
-			return nil, err
+			continue
 		}
-		scores[i].Rank = user.Rank
+		scores = append(scores, *score)
 	}
-
-	return scores, err
+	return scores, nil
 }
 
 // Helper method to get a score by ID within a transaction
@@ -495,16 +537,3 @@ func (u *User) SetPassword(password string) error {
 func (u *User) CheckPassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
-
-func (u *User) SetRefreshToken(token string) error {
-	hashedToken, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-	u.RefreshToken = string(hashedToken)
-	return nil
-}
-
-func (u *User) CheckRefreshToken(token string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.RefreshToken), []byte(token))
-}
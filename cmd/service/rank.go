@@ -1,8 +1,195 @@
 package main
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/liqmix/slaptrax/internal/judge"
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
 const MaxScore = 100000
 
-func getRatingValue(s *Score) float64 {
-	scorePerc := float64(s.Score) / float64(100000)
-	return scorePerc * float64(s.Difficulty)
+// RatingVersion identifies the performance formula, bump it whenever
+// getPerformanceValue or the weighting changes so ratings get recalculated
+const RatingVersion = 2
+
+const ratingVersionKey = "meta:rating_version"
+
+const (
+	// Only the best plays count towards the rating
+	ratingTopPlays = 20
+
+	// Weight of each play relative to the one above it
+	ratingDecay = 0.9
+
+	// Steepness of the accuracy curve, near perfect plays are worth the most
+	ratingAccuracyExponent = 4
+)
+
+// Weight of plays on each built in judgement profile, tighter windows are worth more.
+// Custom profiles can be as forgiving as they like, so they're never ranked.
+var judgementWeights = map[string]float64{
+	judge.Lenient.Name:  0.9,
+	judge.Standard.Name: 1,
+	judge.Strict.Name:   1.1,
+}
+
+// Ranked reports if the score counts towards leaderboards and ratings
+func (s *Score) Ranked() bool {
+	_, builtin := judgementWeights[judge.JudgementID(s.Judgement)]
+	return s.Verified && builtin && !s.Invalidated && !s.Unranked
+}
+
+// getPerformanceValue rates a single play from its chart difficulty, accuracy and judgement profile.
+// The score is used as accuracy as it already weighs slips, holds and modifiers.
+func getPerformanceValue(s *Score) float64 {
+	if !s.Ranked() {
+		return 0
+	}
+
+	accuracy := float64(s.Score) / MaxScore
+	accuracy = math.Max(0, math.Min(1, accuracy))
+	weight := judgementWeights[judge.JudgementID(s.Judgement)]
+	return float64(s.Difficulty) * math.Pow(accuracy, ratingAccuracyExponent) * weight
+}
+
+// getRating sums the best play of each chart, weighing every play less than the one above it
+func getRating(scores []Score) float64 {
+	best := make(map[string]float64)
+	for _, score := range scores {
+		key := fmt.Sprintf("%s:%d", score.SongHash, score.Difficulty)
+		if score.PP > best[key] {
+			best[key] = score.PP
+		}
+	}
+
+	values := make([]float64, 0, len(best))
+	for _, pp := range best {
+		values = append(values, pp)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+
+	rating := 0.0
+	weight := 1.0
+	for i, pp := range values {
+		if i >= ratingTopPlays {
+			break
+		}
+		rating += pp * weight
+		weight *= ratingDecay
+	}
+	return rating
+}
+
+// Helper function to recompute and store the rating of a user within a transaction.
+// If recalculate is set, the PP of every score is recomputed first.
+func (s *Store) updateRatingInTx(txn *badger.Txn, user *User, recalculate bool) error {
+	scores, err := s.getUserScoresInTx(txn, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if recalculate {
+		for i := range scores {
+			scores[i].PP = getPerformanceValue(&scores[i])
+
+			scoreData, err := json.Marshal(scores[i])
+			if err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(scorePrefix+fmt.Sprintf("%d", scores[i].ID)), scoreData); err != nil {
+				return err
+			}
+		}
+	}
+
+	user.Rank = getRating(scores)
+	user.RatingVersion = RatingVersion
+	user.UpdatedAt = time.Now()
+
+	userData, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set([]byte(userPrefix+fmt.Sprintf("%d", user.ID)), userData); err != nil {
+		return err
+	}
+	return s.updateLeaderboardRankInTx(txn, user, scores)
+}
+
+// RecalculateRatings recomputes the PP of every score and the rating of every user
+func (s *Store) RecalculateRatings() (int, error) {
+	var ids []uint
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(userPrefix)
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix); it.Next() {
+			var id uint
+			if _, err := fmt.Sscanf(string(it.Item().Key()[len(opts.Prefix):]), "%d", &id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// One transaction per user to stay within badger's transaction limits
+	for _, id := range ids {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			user, err := s.getUserInTx(txn, id)
+			if err != nil {
+				return err
+			}
+			return s.updateRatingInTx(txn, user, true)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(ratingVersionKey), []byte(fmt.Sprintf("%d", RatingVersion)))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Info("Recalculated ratings of %d users for rating version %d", len(ids), RatingVersion)
+	return len(ids), nil
+}
+
+// RecalculateRatingsIfOutdated runs RecalculateRatings when the stored ratings use an older formula
+func (s *Store) RecalculateRatingsIfOutdated() error {
+	version := 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(ratingVersionKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			_, err := fmt.Sscanf(string(val), "%d", &version)
+			return err
+		})
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+
+	if version == RatingVersion {
+		return nil
+	}
+	_, err = s.RecalculateRatings()
+	return err
 }
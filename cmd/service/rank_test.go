@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+func TestPerformanceValue(t *testing.T) {
+	score := func(judgement string, change func(s *Score)) *Score {
+		s := &Score{Score: MaxScore, Difficulty: 10, Verified: true, Judgement: judgement}
+		if change != nil {
+			change(s)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		score    *Score
+		expected float64
+	}{
+		{"standard", score(judge.Standard.Name, nil), 10},
+		{"from before profiles", score("", nil), 10},
+		{"lenient", score(judge.Lenient.Name, nil), 9},
+		{"strict", score(judge.Strict.Name, nil), 11},
+		{"custom profile", score(judge.CustomProfile+"-0badf00d", nil), 0},
+		{"unverified", score(judge.Standard.Name, func(s *Score) { s.Verified = false }), 0},
+		{"unranked", score(judge.Standard.Name, func(s *Score) { s.Unranked = true }), 0},
+		{"invalidated", score(judge.Standard.Name, func(s *Score) { s.Invalidated = true }), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPerformanceValue(tt.score); got < tt.expected-1e-9 || got > tt.expected+1e-9 {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestScoreModsCustomJudgement(t *testing.T) {
+	custom := judge.Standard
+	custom.Name = judge.CustomProfile
+	custom.Slap = judge.Window{Early: 500, Late: 500}
+
+	tests := []struct {
+		name     string
+		options  judge.Options
+		unranked bool
+	}{
+		{"built in profile", judge.Options{Profile: judge.Strict}, false},
+		{"custom profile", judge.Options{Profile: custom}, true},
+		{"unranked modifier", judge.Options{Mods: judge.ModShuffle}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay := judge.NewReplay(tt.options)
+			score := &Score{}
+			var err error
+			if score.Judgement, err = scoreJudgement(score, replay); err != nil {
+				t.Fatal(err)
+			}
+			if err := scoreMods(score, replay); err != nil {
+				t.Fatal(err)
+			}
+			if score.Unranked != tt.unranked {
+				t.Errorf("expected unranked %v, got %v", tt.unranked, score.Unranked)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
+// Abuse protection is configured through the environment:
+//
+//	RATE_LIMIT_IP        requests per IP on auth routes, default "30/1m"
+//	RATE_LIMIT_USERNAME  login attempts per username, default "10/1m"
+//	RATE_LIMIT_REGISTER  registrations per IP, default "5/1h"
+//	LOCKOUT_THRESHOLD    failed logins before an account is locked, default 5
+//	LOCKOUT_DURATION     how long an account stays locked, default "15m"
+//	LOCKOUT_PERSIST      keep lockouts in the database across restarts, default true
+//	TRUSTED_PROXIES      comma separated IPs or CIDRs of proxies whose X-Forwarded-For
+//	                     is trusted for the client IP, default none
+
+const lockoutPrefix = "lockout:"
+
+// RateLimit allows Burst requests, refilling Burst tokens every Per
+type RateLimit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseRateLimit parses limits in the form "10/1m"
+func ParseRateLimit(s string) (RateLimit, error) {
+	count, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	burst, err := strconv.Atoi(count)
+	if err != nil || burst < 1 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit count %q", count)
+	}
+
+	duration, err := time.ParseDuration(per)
+	if err != nil || duration <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit period %q", per)
+	}
+	return RateLimit{Burst: burst, Per: duration}, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a set of in-memory token buckets sharing one limit
+type Limiter struct {
+	limit RateLimit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(limit RateLimit) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key, returning how long to wait if there is none
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	rate := float64(l.limit.Burst) / l.limit.Per.Seconds()
+
+	// Drop buckets that have refilled completely
+	if now.Sub(l.lastSweep) > l.limit.Per {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > l.limit.Per {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Lockout is the failed login state of a username
+type Lockout struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// Lockouts tracks failed logins per username, locking accounts after too many
+type Lockouts struct {
+	threshold int
+	duration  time.Duration
+	store     *Store // nil if lockouts aren't persisted
+
+	mu        sync.Mutex
+	entries   map[string]*Lockout
+	lastSweep time.Time
+}
+
+func NewLockouts(threshold int, duration time.Duration, store *Store) *Lockouts {
+	return &Lockouts{
+		threshold: threshold,
+		duration:  duration,
+		store:     store,
+		entries:   make(map[string]*Lockout),
+		lastSweep: time.Now(),
+	}
+}
+
+// Check returns how long the account stays locked, zero if it isn't
+func (l *Lockouts) Check(username string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := l.get(username)
+	if entry == nil {
+		return 0
+	}
+	if wait := time.Until(entry.LockedUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Fail records a failed login, returning how long the account is now locked for
+func (l *Lockouts) Fail(username string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	entry := l.get(username)
+	if entry == nil || now.Sub(entry.LastFailure) > l.duration {
+		entry = &Lockout{}
+		l.entries[username] = entry
+	}
+
+	entry.Failures++
+	entry.LastFailure = now
+
+	var wait time.Duration
+	if entry.Failures >= l.threshold {
+		entry.Failures = 0
+		entry.LockedUntil = now.Add(l.duration)
+		wait = l.duration
+	}
+
+	if l.store != nil {
+		if err := l.store.SetLockout(username, entry, l.duration); err != nil {
+			logger.Error("Failed to persist lockout of %s: %v", username, err)
+		}
+	}
+	return wait
+}
+
+// Reset clears the failed logins of an account after a successful login
+func (l *Lockouts) Reset(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.entries[username]; !ok {
+		return
+	}
+	delete(l.entries, username)
+
+	if l.store != nil {
+		if err := l.store.DeleteLockout(username); err != nil {
+			logger.Error("Failed to clear lockout of %s: %v", username, err)
+		}
+	}
+}
+
+// Must be called with l.mu held
+func (l *Lockouts) get(username string) *Lockout {
+	// Drop entries that are no longer locked and whose failures have expired
+	now := time.Now()
+	if now.Sub(l.lastSweep) > l.duration {
+		for k, entry := range l.entries {
+			if now.After(entry.LockedUntil) && now.Sub(entry.LastFailure) > l.duration {
+				delete(l.entries, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	if entry, ok := l.entries[username]; ok {
+		return entry
+	}
+	if l.store == nil {
+		return nil
+	}
+
+	entry, err := l.store.GetLockout(username)
+	if err != nil {
+		logger.Error("Failed to load lockout of %s: %v", username, err)
+		return nil
+	}
+	if entry != nil {
+		l.entries[username] = entry
+	}
+	return entry
+}
+
+// RateLimits are the limiters applied to the auth routes
+type RateLimits struct {
+	IP       *Limiter
+	Username *Limiter
+	Register *Limiter
+	Lockouts *Lockouts
+}
+
+var limits *RateLimits
+
+// LoadRateLimits sets up limits from the environment
+func LoadRateLimits(store *Store) error {
+	ip, err := rateLimitFromEnv("RATE_LIMIT_IP", "30/1m")
+	if err != nil {
+		return err
+	}
+	username, err := rateLimitFromEnv("RATE_LIMIT_USERNAME", "10/1m")
+	if err != nil {
+		return err
+	}
+	register, err := rateLimitFromEnv("RATE_LIMIT_REGISTER", "5/1h")
+	if err != nil {
+		return err
+	}
+
+	threshold := 5
+	if v := os.Getenv("LOCKOUT_THRESHOLD"); v != "" {
+		threshold, err = strconv.Atoi(v)
+		if err != nil || threshold < 1 {
+			return fmt.Errorf("invalid LOCKOUT_THRESHOLD %q", v)
+		}
+	}
+
+	duration := 15 * time.Minute
+	if v := os.Getenv("LOCKOUT_DURATION"); v != "" {
+		duration, err = time.ParseDuration(v)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid LOCKOUT_DURATION %q", v)
+		}
+	}
+
+	if os.Getenv("LOCKOUT_PERSIST") == "false" {
+		store = nil
+	}
+
+	limits = &RateLimits{
+		IP:       NewLimiter(ip),
+		Username: NewLimiter(username),
+		Register: NewLimiter(register),
+		Lockouts: NewLockouts(threshold, duration, store),
+	}
+	return nil
+}
+
+// TrustedProxies returns the proxies set in TRUSTED_PROXIES, nil if none are trusted
+func TrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+func rateLimitFromEnv(name, fallback string) (RateLimit, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		v = fallback
+	}
+
+	limit, err := ParseRateLimit(v)
+	if err != nil {
+		return RateLimit{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return limit, nil
+}
+
+// rateLimitMiddleware limits requests per client IP, see TrustedProxies, and,
+// if a username limiter is given, per username in the JSON body
+func rateLimitMiddleware(ip, username *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, wait := ip.Allow(c.ClientIP()); !ok {
+			tooManyRequests(c, wait, "Too many requests")
+			return
+		}
+
+		if username != nil {
+			if name := peekUsername(c); name != "" {
+				if ok, wait := username.Allow(name); !ok {
+					tooManyRequests(c, wait, "Too many requests")
+					return
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+func tooManyRequests(c *gin.Context, wait time.Duration, message string) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message})
+	c.Abort()
+}
+
+// peekUsername reads the username from a JSON body, leaving the body for the handler
+func peekUsername(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+
+	// Credentials are small, anything past this is left for the handler to reject
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 4096))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if err != nil {
+		return ""
+	}
+
+	var input struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &input); err != nil {
+		return ""
+	}
+	return input.Username
+}
+
+// GetLockout retrieves the persisted failed logins of a username
+func (s *Store) GetLockout(username string) (*Lockout, error) {
+	var entry Lockout
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(lockoutPrefix + username))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &entry)
+		})
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SetLockout persists the failed logins of a username, expiring once they no longer matter
+func (s *Store) SetLockout(username string, entry *Lockout, ttl time.Duration) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry([]byte(lockoutPrefix+username), data).WithTTL(ttl))
+	})
+}
+
+// DeleteLockout clears the persisted failed logins of a username
+func (s *Store) DeleteLockout(username string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(lockoutPrefix + username))
+	})
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected RateLimit
+		valid    bool
+	}{
+		{"10/1m", RateLimit{Burst: 10, Per: time.Minute}, true},
+		{"5/1h", RateLimit{Burst: 5, Per: time.Hour}, true},
+		{"10", RateLimit{}, false},
+		{"0/1m", RateLimit{}, false},
+		{"ten/1m", RateLimit{}, false},
+		{"10/0s", RateLimit{}, false},
+		{"10/soon", RateLimit{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimit(tt.value)
+		if (err == nil) != tt.valid {
+			t.Errorf("%q: expected valid %v, got %v", tt.value, tt.valid, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%q: expected %+v, got %+v", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(RateLimit{Burst: 3, Per: time.Hour})
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("expected a request past the burst to be limited")
+	}
+	if wait <= 0 || wait > 20*time.Minute {
+		t.Errorf("expected to wait for one token, about 20m, got %v", wait)
+	}
+
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("expected keys to be limited separately")
+	}
+}
+
+func TestLockouts(t *testing.T) {
+	l := NewLockouts(3, time.Hour, nil)
+	for i := 0; i < 2; i++ {
+		if wait := l.Fail("ann"); wait != 0 {
+			t.Fatalf("expected failure %d not to lock, got %v", i+1, wait)
+		}
+	}
+	if wait := l.Fail("ann"); wait != time.Hour {
+		t.Fatalf("expected the threshold to lock for an hour, got %v", wait)
+	}
+	if wait := l.Check("ann"); wait <= 0 {
+		t.Error("expected the account to stay locked")
+	}
+	if wait := l.Check("bob"); wait != 0 {
+		t.Errorf("expected other accounts not to be locked, got %v", wait)
+	}
+
+	l.Fail("bob")
+	l.Reset("bob")
+	l.Fail("bob")
+	if wait := l.Fail("bob"); wait != 0 {
+		t.Error("expected a successful login to clear failures")
+	}
+}
+
+func TestLockoutsSweep(t *testing.T) {
+	l := NewLockouts(3, time.Hour, nil)
+	l.Fail("ann")
+	l.Fail("bob")
+	l.entries["ann"].LastFailure = time.Now().Add(-2 * time.Hour)
+	l.lastSweep = time.Now().Add(-2 * time.Hour)
+
+	l.Check("carl")
+	if _, ok := l.entries["ann"]; ok {
+		t.Error("expected expired failures to be swept")
+	}
+	if _, ok := l.entries["bob"]; !ok {
+		t.Error("expected recent failures to be kept")
+	}
+}
+
+func TestLockoutsPersist(t *testing.T) {
+	s := newTestStore(t)
+
+	NewLockouts(1, time.Hour, s).Fail("ann")
+	if wait := NewLockouts(1, time.Hour, s).Check("ann"); wait <= 0 {
+		t.Error("expected the lockout to outlast the limiter")
+	}
+
+	l := NewLockouts(1, time.Hour, s)
+	l.Check("ann")
+	l.Reset("ann")
+	if wait := NewLockouts(1, time.Hour, s).Check("ann"); wait != 0 {
+		t.Errorf("expected a reset to clear the persisted lockout, got %v", wait)
+	}
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	defer func(l *RateLimits) { limits = l }(limits)
+	for _, name := range []string{"RATE_LIMIT_IP", "RATE_LIMIT_USERNAME", "RATE_LIMIT_REGISTER", "LOCKOUT_THRESHOLD", "LOCKOUT_DURATION"} {
+		t.Setenv(name, "")
+	}
+
+	if err := LoadRateLimits(nil); err != nil {
+		t.Fatal(err)
+	}
+	if limits.IP.limit != (RateLimit{Burst: 30, Per: time.Minute}) || limits.Lockouts.threshold != 5 {
+		t.Errorf("expected the defaults, got %+v and %d", limits.IP.limit, limits.Lockouts.threshold)
+	}
+
+	t.Setenv("RATE_LIMIT_REGISTER", "2/1m")
+	t.Setenv("LOCKOUT_DURATION", "1h")
+	if err := LoadRateLimits(nil); err != nil {
+		t.Fatal(err)
+	}
+	if limits.Register.limit != (RateLimit{Burst: 2, Per: time.Minute}) || limits.Lockouts.duration != time.Hour {
+		t.Errorf("expected the environment to override the defaults, got %+v and %v", limits.Register.limit, limits.Lockouts.duration)
+	}
+
+	for name, value := range map[string]string{
+		"RATE_LIMIT_IP":     "lots",
+		"LOCKOUT_THRESHOLD": "0",
+		"LOCKOUT_DURATION":  "-1m",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if err := LoadRateLimits(nil); err == nil {
+				t.Errorf("expected %s=%q to be rejected", name, value)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+	if proxies := TrustedProxies(); proxies != nil {
+		t.Errorf("expected no trusted proxies, got %v", proxies)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", " 10.0.0.1, 192.168.0.0/16 ,")
+	if proxies := TrustedProxies(); !reflect.DeepEqual(proxies, []string{"10.0.0.1", "192.168.0.0/16"}) {
+		t.Errorf("expected both proxies, got %v", proxies)
+	}
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+	}{
+		// Without a trusted proxy every request comes from the connecting address
+		{"untrusted", nil, "203.0.113.7:1234"},
+		// Behind a trusted proxy the forwarded address is limited, not the proxy
+		{"trusted proxy", []string{"203.0.113.7"}, "203.0.113.7:1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			r.GET("/", rateLimitMiddleware(NewLimiter(RateLimit{Burst: 1, Per: time.Hour}), nil), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			codes := make([]int, 0, 2)
+			for _, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = tt.remote
+				req.Header.Set("X-Forwarded-For", forwarded)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+			}
+
+			expected := []int{http.StatusOK, http.StatusTooManyRequests}
+			if tt.proxies != nil {
+				expected = []int{http.StatusOK, http.StatusOK}
+			}
+			if !reflect.DeepEqual(codes, expected) {
+				t.Errorf("expected %v, got %v", expected, codes)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Key prefixes for refresh token sessions
+const (
+	sessionPrefix    = "session:"
+	userSessionIndex = "user_session:"
+)
+
+var (
+	errSessionNotFound = errors.New("session not found")
+	errSessionReused   = errors.New("refresh token reused")
+)
+
+// Session is a single login of a user, identified by the ID carried in its refresh token
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     uint      `json:"user_id"`
+	TokenHash  string    `json:"token_hash,omitempty"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+
+	// Only set when listing sessions
+	Current bool `json:"current,omitempty"`
+}
+
+func (s *Session) Clean() {
+	s.TokenHash = ""
+}
+
+func (s *Session) Expired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// CreateSession starts a new session for the user, returning it with its refresh token
+func (s *Store) CreateSession(userID uint, device, ip string) (*Session, string, error) {
+	id, err := generateSessionID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, hash, err := GenerateRefreshToken(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:         id,
+		UserID:     userID,
+		TokenHash:  hash,
+		Device:     device,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		if err := s.setSessionInTx(txn, session); err != nil {
+			return err
+		}
+		return txn.Set([]byte(fmt.Sprintf("%s%d:%s", userSessionIndex, userID, id)), nil)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// RotateSession exchanges a refresh token for a new one.
+// Presenting a token that was already rotated revokes the whole session.
+func (s *Store) RotateSession(refreshToken, ip string) (*Session, string, error) {
+	id, secret, err := parseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, "", errSessionNotFound
+	}
+
+	var session *Session
+	var token string
+	reused := false
+	err = s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		session, err = s.getSessionInTx(txn, id)
+		if err != nil {
+			return err
+		}
+
+		if session.Expired() {
+			return s.deleteSessionInTx(txn, session)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(session.TokenHash), []byte(hashRefreshSecret(secret))) != 1 {
+			reused = true
+			return s.deleteSessionInTx(txn, session)
+		}
+
+		var hash string
+		token, hash, err = GenerateRefreshToken(id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		session.TokenHash = hash
+		session.IP = ip
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(refreshTokenTTL)
+		return s.setSessionInTx(txn, session)
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, "", errSessionNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		return nil, "", errSessionReused
+	}
+	if token == "" {
+		return nil, "", errSessionNotFound
+	}
+	return session, token, nil
+}
+
+// GetSession retrieves an unexpired session by ID
+func (s *Store) GetSession(id string) (*Session, error) {
+	var session *Session
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		session, err = s.getSessionInTx(txn, id)
+		return err
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.Expired() {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// GetUserSessions lists the unexpired sessions of a user, most recently used first
+func (s *Store) GetUserSessions(userID uint) ([]Session, error) {
+	sessions := make([]Session, 0)
+	prefix := []byte(fmt.Sprintf("%s%d:", userSessionIndex, userID))
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			id := string(it.Item().Key()[len(prefix):])
+
+			session, err := s.getSessionInTx(txn, id)
+			if err != nil || session.Expired() {
+				continue
+			}
+			sessions = append(sessions, *session)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteSession revokes a session, only if it belongs to the user
+func (s *Store) DeleteSession(userID uint, id string) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		session, err := s.getSessionInTx(txn, id)
+		if err != nil {
+			return err
+		}
+		if session.UserID != userID {
+			return errSessionNotFound
+		}
+		return s.deleteSessionInTx(txn, session)
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return errSessionNotFound
+	}
+	return err
+}
+
+// Helper function to revoke every session of a user within a transaction
+func (s *Store) deleteUserSessionsInTx(txn *badger.Txn, userID uint) error {
+	prefix := []byte(fmt.Sprintf("%s%d:", userSessionIndex, userID))
+
+	var ids []string
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.PrefetchValues = false
+
+	it := txn.NewIterator(opts)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		ids = append(ids, string(it.Item().Key()[len(prefix):]))
+	}
+	it.Close()
+
+	for _, id := range ids {
+		if err := txn.Delete([]byte(sessionPrefix + id)); err != nil {
+			return err
+		}
+		if err := txn.Delete([]byte(fmt.Sprintf("%s%d:%s", userSessionIndex, userID, id))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to get a session within a transaction
+func (s *Store) getSessionInTx(txn *badger.Txn, id string) (*Session, error) {
+	item, err := txn.Get([]byte(sessionPrefix + id))
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *Store) setSessionInTx(txn *badger.Txn, session *Session) error {
+	sessionData, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return txn.Set([]byte(sessionPrefix+session.ID), sessionData)
+}
+
+func (s *Store) deleteSessionInTx(txn *badger.Txn, session *Session) error {
+	if err := txn.Delete([]byte(sessionPrefix + session.ID)); err != nil {
+		return err
+	}
+	return txn.Delete([]byte(fmt.Sprintf("%s%d:%s", userSessionIndex, session.UserID, session.ID)))
+}
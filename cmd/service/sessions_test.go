@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestRotateSessionReuse(t *testing.T) {
+	s := newTestStore(t)
+	user := newTestUser(t, s, "slapper")
+
+	session, first, err := s.CreateSession(user.ID, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := s.RotateSession(first, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("expected the refresh token to be rotated")
+	}
+
+	// Presenting the rotated token again revokes the session
+	if _, _, err := s.RotateSession(first, "127.0.0.1"); err != errSessionReused {
+		t.Fatalf("expected %v, got %v", errSessionReused, err)
+	}
+	if _, _, err := s.RotateSession(second, "127.0.0.1"); err != errSessionNotFound {
+		t.Errorf("expected the latest token to be revoked with the session, got %v", err)
+	}
+	if got, err := s.GetSession(session.ID); err != nil || got != nil {
+		t.Errorf("expected the session to be deleted, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Key prefix for synced client settings
+const settingsPrefix = "settings:"
+
+// Limits on what a client can store
+const (
+	maxSettings        = 64
+	maxSettingKeyLen   = 64
+	maxSettingValueLen = 1024
+)
+
+// SettingValue is a single synced client setting with the time it was last changed.
+// The service doesn't interpret values, the client owns the settings format.
+type SettingValue struct {
+	Value    json.RawMessage `json:"value"`
+	Modified time.Time       `json:"modified"`
+}
+
+func validateSettings(settings map[string]SettingValue) error {
+	if len(settings) > maxSettings {
+		return fmt.Errorf("too many settings")
+	}
+	for key, setting := range settings {
+		if key == "" || len(key) > maxSettingKeyLen {
+			return fmt.Errorf("invalid setting name %q", key)
+		}
+		if len(setting.Value) > maxSettingValueLen || !json.Valid(setting.Value) {
+			return fmt.Errorf("invalid value for setting %q", key)
+		}
+	}
+	return nil
+}
+
+// GetUserSettings retrieves the synced settings of a user
+func (s *Store) GetUserSettings(userID uint) (map[string]SettingValue, error) {
+	var settings map[string]SettingValue
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		settings, err = s.getSettingsInTx(txn, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// MergeUserSettings stores every incoming setting that changed more recently
+// than the stored one, returning the merged settings
+func (s *Store) MergeUserSettings(userID uint, incoming map[string]SettingValue) (map[string]SettingValue, error) {
+	var merged map[string]SettingValue
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		merged, err = s.getSettingsInTx(txn, userID)
+		if err != nil {
+			return err
+		}
+
+		for key, setting := range incoming {
+			if current, ok := merged[key]; ok && !setting.Modified.After(current.Modified) {
+				continue
+			}
+			if _, ok := merged[key]; !ok && len(merged) >= maxSettings {
+				continue
+			}
+			merged[key] = setting
+		}
+
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(settingsPrefix+fmt.Sprintf("%d", userID)), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// Helper function to get the settings of a user within a transaction
+func (s *Store) getSettingsInTx(txn *badger.Txn, userID uint) (map[string]SettingValue, error) {
+	settings := make(map[string]SettingValue)
+
+	item, err := txn.Get([]byte(settingsPrefix + fmt.Sprintf("%d", userID)))
+	if err == badger.ErrKeyNotFound {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
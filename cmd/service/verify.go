@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+// Allowed difference between the submitted and the recomputed score.
+// The client judges once per frame while the simulation runs per ms.
+const scoreTolerance = MaxScore / 100
+
+var (
+	errUnverifiable     = errors.New("score cannot be verified")
+	errScoreMismatch    = errors.New("submitted score does not match replay")
+	errUnknownJudgement = errors.New("unknown judgement profile")
+)
+
+// scoreJudgement returns the ID of the profile a score is ranked with.
+// Replays carry their profile, without one only built in profiles are taken at their word.
+func scoreJudgement(score *Score, replay *judge.Replay) (string, error) {
+	if replay != nil {
+		profile := replay.Options.JudgementProfile()
+		if err := profile.Validate(); err != nil {
+			return "", err
+		}
+		return profile.ID(), nil
+	}
+
+	id := judge.JudgementID(score.Judgement)
+	if judge.GetProfile(id).Name != id {
+		return "", errUnknownJudgement
+	}
+	return id, nil
+}
+
+// scoreMods sets the modifiers of a score, taken from its replay when there is one.
+// Scores with unranked modifiers or on a custom judgement profile are marked unranked.
+func scoreMods(score *Score, replay *judge.Replay) error {
+	if replay != nil {
+		score.Mods = replay.Options.Mods
+	}
+	if err := score.Mods.Validate(); err != nil {
+		return err
+	}
+	_, builtin := judgementWeights[judge.JudgementID(score.Judgement)]
+	score.Unranked = score.Mods.Unranked() || !builtin
+	return nil
+}
+
+// verifyScore re-simulates the replay against the chart of the score, modifiers included.
+// The replay has to be of that chart. On success the score is overwritten with the recomputed values.
+func verifyScore(score *Score, bpm int, chart *schema.ChartDataV2, replay *judge.Replay) error {
+	if replay == nil || len(replay.Events) == 0 {
+		return errUnverifiable
+	}
+	if replay.Song != score.SongHash || replay.Difficulty != score.Difficulty {
+		return errScoreMismatch
+	}
+
+	result, err := judge.Simulate(chart, bpm, replay)
+	if err != nil {
+		return err
+	}
+
+	diff := score.Score - result.Total()
+	if diff < -scoreTolerance || diff > scoreTolerance {
+		return errScoreMismatch
+	}
+
+	score.Score = result.Total()
+	score.Accuracy = result.Accuracy()
+	score.MaxCombo = result.MaxCombo
+	score.Verified = true
+	return nil
+}
@@ -1,9 +1,9 @@
 package parser
 
 import (
-	"crypto/sha256"
 	"fmt"
 
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/logger"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/types/schema"
@@ -84,29 +84,7 @@ func (p *JSONParser) convertToSong(data *schema.SongDataV2) (*types.Song, error)
 
 // generateHash creates a unique hash for the song based on its data
 func (p *JSONParser) generateHash(data *schema.SongDataV2) string {
-	hasher := sha256.New()
-	
-	// Hash metadata
-	hasher.Write([]byte(data.Metadata.Title))
-	hasher.Write([]byte(data.Metadata.Artist))
-	hasher.Write([]byte(fmt.Sprintf("%d", data.Metadata.BPM)))
-	hasher.Write([]byte(data.Audio.File))
-	
-	// Hash chart data in deterministic order
-	for _, chartData := range data.Charts {
-		hasher.Write([]byte(fmt.Sprintf("diff:%d", chartData.Difficulty)))
-		hasher.Write([]byte(fmt.Sprintf("notes:%d", chartData.NoteCount)))
-		
-		// Include some track data to ensure uniqueness
-		for trackName, notes := range chartData.Tracks {
-			hasher.Write([]byte(trackName))
-			for _, note := range notes {
-				hasher.Write([]byte(fmt.Sprintf("t:%d:d:%d", note.Time, note.Duration)))
-			}
-		}
-	}
-	
-	return fmt.Sprintf("%x", hasher.Sum(nil))
+	return data.Hash()
 }
 
 // convertToChart converts schema.ChartDataV2 to types.Chart
@@ -115,6 +93,8 @@ func (p *JSONParser) convertToChart(song *types.Song, data *schema.ChartDataV2)
 		TotalNotes:     data.NoteCount,
 		TotalHoldNotes: data.HoldCount,
 		Tracks:         make([]*types.Track, 0),
+		Tempo:          judge.NewTempoMap(song.BPM, data.Events),
+		Scroll:         types.NewScrollMap(data.Events),
 		EventManager:   types.NewEventManager(),
 	}
 
@@ -154,11 +134,11 @@ func (p *JSONParser) convertToChart(song *types.Song, data *schema.ChartDataV2)
 	p.markMultiNotes(notes)
 
 	// Create tracks from notes
-	beatInterval := song.GetBeatInterval()
 	for _, trackName := range types.TrackNames() {
-		track := types.NewTrack(trackName, notes[trackName], beatInterval)
+		track := types.NewTrack(trackName, notes[trackName], chart.Tempo, chart.Scroll)
 		chart.Tracks = append(chart.Tracks, track)
 	}
+	chart.CountNotes()
 
 	// Convert events
 	if len(data.Events) > 0 {
@@ -25,6 +25,10 @@ func (m *Manager) SetBPM(bpm float64) {
 	m.tracker.SetBPM(bpm)
 }
 
+func (m *Manager) SetTempoMap(tempo *TempoMap) {
+	m.tracker.SetTempoMap(tempo)
+}
+
 func (m *Manager) SetTrigger(pos BeatPosition, trigger func()) {
 	m.triggerMap.AddTrigger(pos, trigger)
 }
@@ -0,0 +1,99 @@
+package beats
+
+import (
+	"math"
+	"sort"
+)
+
+// Tempo used when a chart doesn't give a usable one
+const defaultBPM = 120
+
+// TempoChange sets the tempo from Time (ms) onwards
+type TempoChange struct {
+	Time int64
+	BPM  float64
+}
+
+type tempoSegment struct {
+	time      float64 // ms the segment starts at
+	beat      float64 // beats elapsed at the start of the segment
+	msPerBeat float64
+}
+
+// TempoMap converts between song time and beats across tempo changes.
+// Beat 0 is at 0ms, anything before the first change runs at the starting tempo.
+type TempoMap struct {
+	segments []tempoSegment
+}
+
+func NewTempoMap(bpm float64, changes []TempoChange) *TempoMap {
+	if bpm <= 0 {
+		bpm = defaultBPM
+	}
+
+	sorted := make([]TempoChange, 0, len(changes))
+	for _, c := range changes {
+		if c.BPM > 0 {
+			sorted = append(sorted, c)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time < sorted[j].Time
+	})
+
+	m := &TempoMap{
+		segments: []tempoSegment{{msPerBeat: 60000 / bpm}},
+	}
+	for _, c := range sorted {
+		last := &m.segments[len(m.segments)-1]
+		time := float64(max(c.Time, 0))
+
+		// Later changes at the same time win
+		if time == last.time {
+			last.msPerBeat = 60000 / c.BPM
+			continue
+		}
+		m.segments = append(m.segments, tempoSegment{
+			time:      time,
+			beat:      last.beat + (time-last.time)/last.msPerBeat,
+			msPerBeat: 60000 / c.BPM,
+		})
+	}
+	return m
+}
+
+func (m *TempoMap) segmentAtTime(ms float64) tempoSegment {
+	i := sort.Search(len(m.segments), func(i int) bool {
+		return m.segments[i].time > ms
+	})
+	return m.segments[max(i-1, 0)]
+}
+
+func (m *TempoMap) segmentAtBeat(beat float64) tempoSegment {
+	i := sort.Search(len(m.segments), func(i int) bool {
+		return m.segments[i].beat > beat
+	})
+	return m.segments[max(i-1, 0)]
+}
+
+// BeatAt returns the (fractional) beat at the given time
+func (m *TempoMap) BeatAt(ms int64) float64 {
+	s := m.segmentAtTime(float64(ms))
+	return s.beat + (float64(ms)-s.time)/s.msPerBeat
+}
+
+// TimeAt returns the time of the given beat, rounded to the ms
+func (m *TempoMap) TimeAt(beat float64) int64 {
+	s := m.segmentAtBeat(beat)
+	return int64(math.Round(s.time + (beat-s.beat)*s.msPerBeat))
+}
+
+// BPMAt returns the tempo at the given time
+func (m *TempoMap) BPMAt(ms int64) float64 {
+	return 60000 / m.segmentAtTime(float64(ms)).msPerBeat
+}
+
+// BeatInterval returns the length of a beat in ms at the given time
+func (m *TempoMap) BeatInterval(ms int64) int64 {
+	return int64(m.segmentAtTime(float64(ms)).msPerBeat)
+}
@@ -0,0 +1,111 @@
+package beats
+
+import (
+	"math"
+	"testing"
+)
+
+// 120 bpm, 240 bpm from 2000ms (beat 4), 60 bpm from 3000ms (beat 8)
+func testTempoMap() *TempoMap {
+	return NewTempoMap(120, []TempoChange{
+		{Time: 3000, BPM: 60},
+		{Time: 2000, BPM: 240},
+	})
+}
+
+func TestTempoMapConversions(t *testing.T) {
+	tempo := testTempoMap()
+
+	tests := []struct {
+		ms   int64
+		beat float64
+		bpm  float64
+	}{
+		{-1000, -2, 120},
+		{0, 0, 120},
+		{500, 1, 120},
+		{1999, 3.998, 120},
+		{2000, 4, 240},
+		{2125, 4.5, 240},
+		{2750, 7, 240},
+		{3000, 8, 60},
+		{4500, 9.5, 60},
+	}
+	for _, tt := range tests {
+		if got := tempo.BeatAt(tt.ms); math.Abs(got-tt.beat) > 1e-9 {
+			t.Errorf("BeatAt(%d) = %v, expected %v", tt.ms, got, tt.beat)
+		}
+		if got := tempo.TimeAt(tt.beat); got != tt.ms {
+			t.Errorf("TimeAt(%v) = %d, expected %d", tt.beat, got, tt.ms)
+		}
+		if got := tempo.BPMAt(tt.ms); got != tt.bpm {
+			t.Errorf("BPMAt(%d) = %v, expected %v", tt.ms, got, tt.bpm)
+		}
+	}
+}
+
+func TestTempoMapRoundTrip(t *testing.T) {
+	tempo := NewTempoMap(130, []TempoChange{
+		{Time: 1234, BPM: 97.5},
+		{Time: 5678, BPM: 181},
+		{Time: 9001, BPM: 130},
+	})
+
+	for ms := int64(-2000); ms <= 12000; ms += 7 {
+		if got := tempo.TimeAt(tempo.BeatAt(ms)); got != ms {
+			t.Fatalf("TimeAt(BeatAt(%d)) = %d", ms, got)
+		}
+	}
+}
+
+func TestTempoMapChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		bpm      float64
+		changes  []TempoChange
+		bpmAt    int64
+		expected float64
+	}{
+		{"no changes", 150, nil, 10000, 150},
+		{"invalid start", 0, nil, 0, defaultBPM},
+		{"change at start", 120, []TempoChange{{Time: 0, BPM: 90}}, 0, 90},
+		{"change before start", 120, []TempoChange{{Time: -500, BPM: 90}}, 100, 90},
+		{"ignores invalid change", 120, []TempoChange{{Time: 1000, BPM: 0}}, 2000, 120},
+		{"later change at same time wins", 120, []TempoChange{{Time: 1000, BPM: 90}, {Time: 1000, BPM: 180}}, 1000, 180},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempo := NewTempoMap(tt.bpm, tt.changes)
+			if got := tempo.BPMAt(tt.bpmAt); got != tt.expected {
+				t.Fatalf("BPMAt(%d) = %v, expected %v", tt.bpmAt, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBeatTrackerFollowsTempo(t *testing.T) {
+	tracker := NewBeatTracker(120, 0)
+	tracker.SetTempoMap(testTempoMap())
+
+	// Beats at 0, 500, 1000, 1500, 2000, 2250, 2500, 2750, 3000, 4000
+	beats := 0
+	for ms := int64(1); ms <= 4000; ms++ {
+		if tracker.Advance(ms) {
+			beats++
+		}
+	}
+	if beats != 9 {
+		t.Fatalf("expected 9 beats, got %d", beats)
+	}
+	if pos := tracker.GetPosition(); pos.Numerator != 9%4 {
+		t.Fatalf("expected position %d, got %d", 9%4, pos.Numerator)
+	}
+
+	// Seeking back doesn't trigger
+	if tracker.Advance(100) {
+		t.Fatal("expected no beat when seeking back")
+	}
+	if !tracker.Advance(500) {
+		t.Fatal("expected a beat after seeking back")
+	}
+}
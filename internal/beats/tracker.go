@@ -1,5 +1,7 @@
 package beats
 
+import "math"
+
 type BeatPosition struct {
 	Numerator   int
 	Denominator int
@@ -17,6 +19,10 @@ type BeatTracker struct {
 	bpm         float64
 	msPerBeat   int64
 	currentTime int64
+
+	// When set, beats are counted from the tempo map instead of a fixed bpm
+	tempo *TempoMap
+	beat  int64
 }
 
 func NewBeatTracker(bpm float64, initTime int64) *BeatTracker {
@@ -32,6 +38,10 @@ func NewBeatTracker(bpm float64, initTime int64) *BeatTracker {
 }
 
 func (bt *BeatTracker) Advance(current int64) bool {
+	if bt.tempo != nil {
+		return bt.advanceTempo(current)
+	}
+
 	if current < bt.currentTime {
 		bt.currentTime = current
 	}
@@ -45,8 +55,29 @@ func (bt *BeatTracker) Advance(current int64) bool {
 	return false
 }
 
+// advanceTempo keeps the position in step with the beats of the tempo map
+func (bt *BeatTracker) advanceTempo(current int64) bool {
+	beat := int64(math.Floor(bt.tempo.BeatAt(current)))
+	bt.currentTime = current
+	if beat == bt.beat {
+		return false
+	}
+
+	// Seeking backwards doesn't count as a beat
+	forward := beat > bt.beat
+	bt.beat = beat
+	bt.Position.Numerator = int(beat % int64(bt.Position.Denominator))
+	if bt.Position.Numerator < 0 {
+		bt.Position.Numerator += bt.Position.Denominator
+	}
+	return forward
+}
+
 func (bt *BeatTracker) SetCurrentTime(currentTime int64) {
 	bt.currentTime = currentTime
+	if bt.tempo != nil {
+		bt.beat = int64(math.Floor(bt.tempo.BeatAt(currentTime)))
+	}
 }
 
 func (bt *BeatTracker) GetPosition() BeatPosition {
@@ -54,10 +85,21 @@ func (bt *BeatTracker) GetPosition() BeatPosition {
 }
 
 func (bt *BeatTracker) SetBPM(bpm float64) {
+	bt.tempo = nil
 	bt.bpm = bpm
 	bt.msPerBeat = int64((60 * 1000) / bpm)
 }
 
+// SetTempoMap makes the tracker follow the tempo changes of a chart
+func (bt *BeatTracker) SetTempoMap(tempo *TempoMap) {
+	bt.tempo = tempo
+	if tempo != nil {
+		bt.bpm = tempo.BPMAt(bt.currentTime)
+		bt.msPerBeat = tempo.BeatInterval(bt.currentTime)
+		bt.SetCurrentTime(bt.currentTime)
+	}
+}
+
 func (bt *BeatTracker) SetPosition(pos BeatPosition) {
 	bt.Position = pos
 }
@@ -3,29 +3,109 @@ package external
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/liqmix/slaptrax/internal/config"
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
+// Requests that can safely be sent again are tried this many times,
+// waiting twice as long after each failure
+var (
+	requestAttempts   = 3
+	requestRetryDelay = 500 * time.Millisecond
 )
 
 // APIClient handles all server communication
 type APIClient struct {
-	baseURL string
-	client  *http.Client
+	baseURL    string
+	client     *http.Client
+	attempts   int
+	retryDelay time.Duration
+
+	// Session kept fresh when the server turns an access token away
+	authMu    sync.Mutex
+	tokens    *TokenPair
+	onRefresh func(*TokenPair)
 }
 
 // NewAPIClient creates a new API client
 func NewAPIClient() *APIClient {
 	return &APIClient{
-		baseURL: config.SERVER_ENDPOINT,
-		client:  &http.Client{Timeout: 10 * time.Second},
+		baseURL:    config.SERVER_ENDPOINT,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		attempts:   requestAttempts,
+		retryDelay: requestRetryDelay,
 	}
 }
 
+// SetBaseURL points the client at another server
+func (c *APIClient) SetBaseURL(baseURL string) {
+	c.baseURL = baseURL
+}
+
+// SetTokens hands the client the session to refresh once its access token expires,
+// calling onRefresh with the new tokens each time. nil ends the session.
+func (c *APIClient) SetTokens(tokens *TokenPair, onRefresh func(*TokenPair)) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	c.tokens = tokens
+	c.onRefresh = onRefresh
+}
+
+// RefreshTokens rotates the tokens of the session right away
+func (c *APIClient) RefreshTokens() (*TokenPair, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if c.tokens == nil {
+		return nil, fmt.Errorf("no session to refresh")
+	}
+	return c.rotateTokens()
+}
+
+// refreshAccessToken returns a new access token to use in place of the rejected one.
+// Requests failing together share a single refresh, as each refresh token only works once.
+func (c *APIClient) refreshAccessToken(rejected string) (string, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if c.tokens == nil {
+		return "", fmt.Errorf("no session to refresh")
+	}
+
+	// Already refreshed by another request
+	if c.tokens.AccessToken != rejected {
+		return c.tokens.AccessToken, nil
+	}
+
+	tokens, err := c.rotateTokens()
+	if err != nil {
+		return "", err
+	}
+	return tokens.AccessToken, nil
+}
+
+// Must be called with c.authMu held
+func (c *APIClient) rotateTokens() (*TokenPair, error) {
+	tokens, err := c.Refresh(c.tokens.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	c.tokens = tokens
+	if c.onRefresh != nil {
+		c.onRefresh(tokens)
+	}
+	return tokens, nil
+}
+
 // Register creates a new user account
 func (c *APIClient) Register(username, password string) error {
 	body := map[string]interface{}{
@@ -47,6 +127,7 @@ func (c *APIClient) Login(username, password string) (*TokenPair, error) {
 	body := map[string]string{
 		"username": username,
 		"password": password,
+		"device":   deviceName(),
 	}
 
 	resp, err := c.post("/login", body)
@@ -83,6 +164,17 @@ func (c *APIClient) Refresh(refreshToken string) (*TokenPair, error) {
 	return &tokens, nil
 }
 
+// Logout ends the server session the access token belongs to
+func (c *APIClient) Logout(accessToken string) error {
+	resp, err := c.authPost("/logout", accessToken, nil)
+	if err != nil {
+		return fmt.Errorf("logout request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
 // GetUser retrieves the user
 func (c *APIClient) GetUser(accessToken string) (*User, error) {
 	resp, err := c.authGet("/user", accessToken)
@@ -99,20 +191,21 @@ func (c *APIClient) GetUser(accessToken string) (*User, error) {
 	return &profile, nil
 }
 
-// UpdateUser updates the user
-// func (c *APIClient) UpdateUser(accessToken string, settings *Settings) error {
-// 	v, err := settings.Value()
-// 	if err != nil {
-// 		return fmt.Errorf("failed to marshal settings: %w", err)
-// 	}
-// 	resp, err := c.authPost("/users", accessToken, v)
-// 	if err != nil {
-// 		return fmt.Errorf("profile update failed: %w", err)
-// 	}
-// 	defer resp.Body.Close()
-
-// 	return nil
-// }
+// SyncSettings sends the locally changed settings, returning the merged settings of the account
+func (c *APIClient) SyncSettings(accessToken string, settings map[string]SettingValue) (map[string]SettingValue, error) {
+	resp, err := c.authPut("/user/settings", accessToken, settings)
+	if err != nil {
+		return nil, fmt.Errorf("settings sync failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var merged map[string]SettingValue
+	if err := json.NewDecoder(resp.Body).Decode(&merged); err != nil {
+		return nil, fmt.Errorf("failed to decode settings: %w", err)
+	}
+
+	return merged, nil
+}
 
 // Get user scores retrieves the scores for a user
 func (c *APIClient) GetUserScores(accessToken string) ([]Score, error) {
@@ -132,7 +225,7 @@ func (c *APIClient) GetUserScores(accessToken string) ([]Score, error) {
 
 // AddScore submits a new score
 func (c *APIClient) AddScore(accessToken string, score *Score) error {
-	resp, err := c.authPost("/scores", accessToken, score)
+	resp, err := c.authPostOnce("/scores", accessToken, score.PlayID, score)
 	if err != nil {
 		return fmt.Errorf("score submission failed: %w", err)
 	}
@@ -141,13 +234,16 @@ func (c *APIClient) AddScore(accessToken string, score *Score) error {
 	return nil
 }
 
-// GetLeaderboard retrieves the leaderboard for a song
-func (c *APIClient) GetLeaderboard(song, difficulty string) ([]Score, error) {
+// GetLeaderboard retrieves a page of the leaderboard for a song played with a judgement profile
+func (c *APIClient) GetLeaderboard(song, difficulty, judgement string, offset, limit int) (*Leaderboard, error) {
 	// Properly URL encode the parameters
 	params := url.Values{}
 	params.Add("song", song)
 	params.Add("difficulty", difficulty)
-	
+	params.Add("judgement", judgement)
+	params.Add("offset", fmt.Sprintf("%d", offset))
+	params.Add("limit", fmt.Sprintf("%d", limit))
+
 	endpoint := "/scores/leaderboard?" + params.Encode()
 	resp, err := c.get(endpoint)
 	if err != nil {
@@ -155,97 +251,172 @@ func (c *APIClient) GetLeaderboard(song, difficulty string) ([]Score, error) {
 	}
 	defer resp.Body.Close()
 
-	var leaderboard []Score
+	var leaderboard Leaderboard
 	if err := json.NewDecoder(resp.Body).Decode(&leaderboard); err != nil {
 		return nil, fmt.Errorf("failed to decode leaderboard: %w", err)
 	}
 
-	return leaderboard, nil
+	return &leaderboard, nil
 }
 
-// get performs a GET request
-func (c *APIClient) get(path string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", c.baseURL+path, nil)
-	if err != nil {
-		return nil, err
-	}
+// GetLeaderboardAround retrieves the leaderboard entries around the user's best score
+func (c *APIClient) GetLeaderboardAround(accessToken, song, difficulty, judgement string, n int) (*Leaderboard, error) {
+	params := url.Values{}
+	params.Add("song", song)
+	params.Add("difficulty", difficulty)
+	params.Add("judgement", judgement)
+	params.Add("range", fmt.Sprintf("%d", n))
 
-	resp, err := c.client.Do(req)
+	resp, err := c.authGet("/scores/leaderboard/around?"+params.Encode(), accessToken)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("leaderboard request failed: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, body)
+	var leaderboard Leaderboard
+	if err := json.NewDecoder(resp.Body).Decode(&leaderboard); err != nil {
+		return nil, fmt.Errorf("failed to decode leaderboard: %w", err)
 	}
 
-	return resp, nil
+	return &leaderboard, nil
 }
 
-// post performs a POST request with JSON body
-func (c *APIClient) post(path string, body interface{}) (*http.Response, error) {
-	jsonData, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
+// deviceName labels the session of this machine on the server
+func deviceName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return runtime.GOOS
 	}
-
-	req, err := http.NewRequest("POST", c.baseURL+path, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	if len(name) > 48 {
+		name = name[:48]
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return fmt.Sprintf("%s (%s)", name, runtime.GOOS)
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+// StatusError is returned when the server answers with an unexpected status
+type StatusError struct {
+	Code int
+	Body string
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, body)
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.Code, e.Body)
+}
+
+// IsRejected reports whether the server refused a request outright,
+// so sending it again won't help
+func IsRejected(err error) bool {
+	var status *StatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch status.Code {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
 	}
+	return status.Code >= 400 && status.Code < 500
+}
 
-	return resp, nil
+// hasStatus reports whether the server answered a request with code
+func hasStatus(err error, code int) bool {
+	var status *StatusError
+	return errors.As(err, &status) && status.Code == code
+}
+
+// get performs a GET request
+func (c *APIClient) get(path string) (*http.Response, error) {
+	return c.send("GET", path, "", "", nil)
+}
+
+// post performs a POST request with JSON body
+func (c *APIClient) post(path string, body interface{}) (*http.Response, error) {
+	return c.send("POST", path, "", "", body)
 }
 
 // authGet performs a GET request with auth header
 func (c *APIClient) authGet(path, token string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", c.baseURL+path, nil)
-	if err != nil {
-		return nil, err
+	return c.send("GET", path, token, "", nil)
+}
+
+// authPost performs a POST request with auth header and JSON body
+func (c *APIClient) authPost(path, token string, body interface{}) (*http.Response, error) {
+	return c.send("POST", path, token, "", body)
+}
+
+// authPostOnce performs a POST request the server applies at most once per key,
+// so it can be sent again if the response is lost
+func (c *APIClient) authPostOnce(path, token, key string, body interface{}) (*http.Response, error) {
+	return c.send("POST", path, token, key, body)
+}
+
+// authPut performs a PUT request with auth header and JSON body
+func (c *APIClient) authPut(path, token string, body interface{}) (*http.Response, error) {
+	return c.send("PUT", path, token, "", body)
+}
+
+// send performs a request, refreshing the access token once if the server no longer accepts it
+func (c *APIClient) send(method, path, token, key string, body interface{}) (*http.Response, error) {
+	var jsonData []byte
+	if body != nil {
+		var err error
+		if jsonData, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+	resp, err := c.do(method, path, token, key, jsonData)
+	if token == "" || !hasStatus(err, http.StatusUnauthorized) {
+		return resp, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, body)
+	token, refreshErr := c.refreshAccessToken(token)
+	if refreshErr != nil {
+		logger.Debug("Failed to refresh access token: %v", refreshErr)
+		return nil, err
 	}
+	return c.do(method, path, token, key, jsonData)
+}
 
-	return resp, nil
+// do sends a request, trying again after timeouts and server errors
+// as long as sending it twice does no harm
+func (c *APIClient) do(method, path, token, key string, jsonData []byte) (*http.Response, error) {
+	retry := method == "GET" || method == "PUT" || key != ""
+
+	delay := c.retryDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := c.sendOnce(method, path, token, key, jsonData)
+
+		var status *StatusError
+		failed := err != nil && (!errors.As(err, &status) || status.Code >= 500)
+		if !failed || !retry || attempt >= c.attempts {
+			return resp, err
+		}
+
+		logger.Debug("%s %s failed, retrying in %v: %v", method, path, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
 }
 
-// authPost performs a POST request with auth header and JSON body
-func (c *APIClient) authPost(path, token string, body interface{}) (*http.Response, error) {
-	jsonData, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
+func (c *APIClient) sendOnce(method, path, token, key string, jsonData []byte) (*http.Response, error) {
+	var body io.Reader
+	if jsonData != nil {
+		body = bytes.NewReader(jsonData)
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL+path, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(method, c.baseURL+path, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
+	if jsonData != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -255,7 +426,7 @@ func (c *APIClient) authPost(path, token string, body interface{}) (*http.Respon
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, body)
+		return nil, &StatusError{Code: resp.StatusCode, Body: string(body)}
 	}
 
 	return resp, nil
@@ -0,0 +1,180 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer only accepts the latest access token, rotating both tokens on refresh.
+// The first pair it hands out has already expired.
+type tokenServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	issued    int
+	refreshes atomic.Int32
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	s := &tokenServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/refresh":
+			var input struct {
+				RefreshToken string `json:"refresh_token"`
+			}
+			json.NewDecoder(r.Body).Decode(&input)
+			if input.RefreshToken != fmt.Sprintf("refresh-%d", s.issued) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			s.refreshes.Add(1)
+			s.issued++
+			json.NewEncoder(w).Encode(TokenPair{
+				AccessToken:  fmt.Sprintf("access-%d", s.issued),
+				RefreshToken: fmt.Sprintf("refresh-%d", s.issued),
+			})
+		case "/user":
+			if s.issued == 0 || r.Header.Get("Authorization") != fmt.Sprintf("Bearer access-%d", s.issued) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(User{Username: "slapper"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testClient(url string) *APIClient {
+	c := NewAPIClient()
+	c.SetBaseURL(url)
+	c.retryDelay = time.Millisecond
+	return c
+}
+
+func TestClientRefreshesOnce(t *testing.T) {
+	server := newTokenServer(t)
+	c := testClient(server.URL)
+
+	var rotated []*TokenPair
+	var mu sync.Mutex
+	c.SetTokens(&TokenPair{AccessToken: "access-0", RefreshToken: "refresh-0"}, func(tokens *TokenPair) {
+		mu.Lock()
+		rotated = append(rotated, tokens)
+		mu.Unlock()
+	})
+
+	// All fail on the expired token at once
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetUser("access-0"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("request failed: %v", err)
+	}
+	if n := server.refreshes.Load(); n != 1 {
+		t.Fatalf("expected 1 refresh, got %d", n)
+	}
+	if len(rotated) != 1 || rotated[0].RefreshToken != "refresh-1" {
+		t.Fatalf("rotated tokens not handed back: %v", rotated)
+	}
+}
+
+func TestClientRefreshRejected(t *testing.T) {
+	server := newTokenServer(t)
+	c := testClient(server.URL)
+	c.SetTokens(&TokenPair{AccessToken: "expired", RefreshToken: "revoked"}, nil)
+
+	_, err := c.GetUser("expired")
+	if !hasStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("expected the original 401, got %v", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var requests atomic.Int32
+	keys := make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get("Idempotency-Key")
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+	c := testClient(server.URL)
+
+	// Scores are sent again under the same key
+	if err := c.AddScore("access", &Score{PlayID: "play"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("expected 2 requests, got %d", n)
+	}
+	for range 2 {
+		if key := <-keys; key != "play" {
+			t.Fatalf("expected idempotency key %q, got %q", "play", key)
+		}
+	}
+
+	// Anything else that isn't safe to repeat is sent once
+	requests.Store(0)
+	if err := c.Logout("access"); err == nil {
+		t.Fatal("expected the server error")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("expected 1 request, got %d", n)
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+	c := testClient(server.URL)
+
+	if _, err := c.GetLeaderboard("song", "1", "standard", 0, 10); !hasStatus(err, http.StatusBadGateway) {
+		t.Fatalf("expected the server error, got %v", err)
+	}
+	if n := requests.Load(); int(n) != c.attempts {
+		t.Fatalf("expected %d requests, got %d", c.attempts, n)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	fallback := time.Now()
+	if got := tokenExpiry("not a token", fallback); !got.Equal(fallback) {
+		t.Fatalf("expected fallback, got %v", got)
+	}
+
+	// {"exp":1700000000}
+	token := "eyJhbGciOiJFUzI1NiJ9.eyJleHAiOjE3MDAwMDAwMDB9.sig"
+	if got := tokenExpiry(token, fallback); got.Unix() != 1700000000 {
+		t.Fatalf("expected expiry from claims, got %v", got)
+	}
+}
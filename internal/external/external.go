@@ -10,16 +10,30 @@ import (
 )
 
 var (
-	client         = &http.Client{Timeout: 10 * time.Second}
-	M              = NewManager("storage")
-	HasConnection  = M.HasConnection
-	GetLoginState  = M.GetLoginState
-	Logout         = M.Logout
-	Login          = M.Login
-	Register       = M.Register
-	GetLeaderboard = M.GetLeaderboard
-	AddScore       = M.AddScore
-	GetScore       = M.GetScore
+	client               = &http.Client{Timeout: 10 * time.Second}
+	M                    = NewManager(DataDir())
+	HasConnection        = M.HasConnection
+	GetLoginState        = M.GetLoginState
+	OnLoginStateChange   = M.OnLoginStateChange
+	Logout               = M.Logout
+	Login                = M.Login
+	Register             = M.Register
+	GetLeaderboard       = M.GetLeaderboard
+	GetLeaderboardAround = M.GetLeaderboardAround
+	AddScore             = M.AddScore
+	PendingScores        = M.PendingScores
+	RejectedScores       = M.RejectedScores
+	AddPlay              = M.AddPlay
+	SaveReplay           = M.SaveReplay
+	GetBestPlay          = M.GetBestPlay
+	GetRecentPlays       = M.GetRecentPlays
+	GetPlayCount         = M.GetPlayCount
+	SetServerOverride    = M.SetServerOverride
+	SetPlayerOverride    = M.SetPlayerOverride
+	GetServerProfile     = M.GetServerProfile
+	SwitchServerProfile  = M.SwitchServerProfile
+	GetScore             = M.GetScore
+	Update               = M.Update
 )
 
 // Opens browser to URL
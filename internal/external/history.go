@@ -0,0 +1,173 @@
+package external
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
+const historyFilename = "history.jsonl"
+
+// PlayRecord is a finished play kept in the local history
+type PlayRecord struct {
+	SongHash   string `json:"song_hash"`
+	Difficulty int    `json:"difficulty"`
+	Score      int    `json:"score"`
+	Slap       int    `json:"slap"`
+	Slip       int    `json:"slip"`
+	Slop       int    `json:"slop"`
+	Early      int    `json:"early"`
+	Late       int    `json:"late"`
+	MaxCombo   int    `json:"max_combo"`
+
+	HoldIntervals    int `json:"hold_intervals"`
+	HoldIntervalsHit int `json:"hold_intervals_hit"`
+
+	Judgement string     `json:"judgement,omitempty"` // ID of the judge.Profile played with
+	Mods      judge.Mods `json:"mods,omitempty"`
+
+	Replay   string    `json:"replay,omitempty"` // Path of the replay file, see SaveReplay
+	PlayedAt time.Time `json:"played_at"`
+
+	// Submitted with the play to the server, see NewPlayID
+	PlayID string `json:"play_id,omitempty"`
+
+	// Why the server refused the play, filled in from the outbox by GetRecentPlays
+	Rejected string `json:"-"`
+}
+
+// History is the append-only record of every play on this machine,
+// stored one JSON object per line so a crash only loses the last play
+type History struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	partial bool
+	records []PlayRecord
+}
+
+// NewHistory creates a history stored at path
+func NewHistory(path string) *History {
+	return &History{path: path}
+}
+
+// Add appends a play to the history
+func (h *History) Add(record PlayRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load()
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal play: %w", err)
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	if h.partial {
+		data = append([]byte{'\n'}, data...)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	h.partial = false
+
+	h.records = append(h.records, record)
+	return nil
+}
+
+// Best returns the highest scoring ranked play of a chart with a judgement profile,
+// nil if there is none
+func (h *History) Best(songHash string, difficulty int, judgement string) *PlayRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load()
+
+	var best *PlayRecord
+	for i := range h.records {
+		r := &h.records[i]
+		if r.SongHash != songHash || r.Difficulty != difficulty || judge.JudgementID(r.Judgement) != judgement || r.Mods.Unranked() {
+			continue
+		}
+		if best == nil || r.Score > best.Score {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	record := *best
+	return &record
+}
+
+// Recent returns the last n plays, most recent first
+func (h *History) Recent(n int) []PlayRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load()
+
+	recent := make([]PlayRecord, 0, n)
+	for i := len(h.records) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, h.records[i])
+	}
+	return recent
+}
+
+// PlayCount returns how many times a chart was played
+func (h *History) PlayCount(songHash string, difficulty int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load()
+
+	count := 0
+	for _, r := range h.records {
+		if r.SongHash == songHash && r.Difficulty == difficulty {
+			count++
+		}
+	}
+	return count
+}
+
+// Must be called with h.mu held
+func (h *History) load() {
+	if h.loaded {
+		return
+	}
+	h.loaded = true
+
+	data, err := os.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to read history: %v", err)
+		return
+	}
+
+	// Start the next play on a new line if the last one was cut short by a crash
+	h.partial = len(data) > 0 && data[len(data)-1] != '\n'
+
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var record PlayRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+		h.records = append(h.records, record)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+package external
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var platformUUID = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+
+// machineID returns the id the OS generates on install
+func machineID() (string, error) {
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+		if err != nil {
+			return "", err
+		}
+		if m := platformUUID.FindSubmatch(out); m != nil {
+			return string(m[1]), nil
+		}
+		return "", fmt.Errorf("no platform uuid")
+	}
+
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if id := strings.TrimSpace(string(data)); id != "" {
+				return id, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no machine id")
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package external
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+// machineID returns the id Windows generates on install
+func machineID() (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+
+	id, _, err := k.GetStringValue("MachineGuid")
+	return id, err
+}
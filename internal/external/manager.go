@@ -1,56 +1,256 @@
 package external
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/liqmix/slaptrax/internal/config"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/logger"
 )
 
+// Number of entries shown on song select
+const leaderboardSize = 10
+
+// Backoff between attempts to submit queued plays
+const (
+	outboxRetryDelay    = 5 * time.Second
+	outboxMaxRetryDelay = 5 * time.Minute
+)
+
 // Manager handles all user state including auth and settings
 type Manager struct {
 	currentUser *User
-	session     *Session
-	loginState  LoginState
-	storage     *Storage
-	rememberMe  bool
-	isConnected func() bool
+	saved       *Settings // Settings as last saved, to find what changed
+
+	storage *Storage
+
+	// Replaced by the client whenever it refreshes the access token
+	sessionMu  sync.Mutex
+	session    *Session
+	rememberMe bool
+
+	// Changed from the connectivity monitor as well as the game
+	stateMu    sync.Mutex
+	loginState LoginState
+	listeners  []func(LoginState)
+	monitor    *Monitor // Replaced on switching servers
+
+	// Server being played against, see resolveServerProfile
+	profile        ServerProfile
+	serverOverride string
+
+	// Player whose data is in use, see playerDir
+	root            string
+	player          string
+	playerOverride  string
+	playerListeners []func()
+
+	// Plays waiting to be submitted, oldest first
+	outboxMu sync.Mutex
+	outbox   []Score
+	flushing bool
+
+	// Changes to the user and settings made in the background, applied by Update.
+	// The game reads both without locking, so only the game loop may change them.
+	updatesMu sync.Mutex
+	updates   []func()
 }
 
 // New creates a new state manager
 func NewManager(storagePath string) *Manager {
 	return &Manager{
-		storage:     NewStorage(storagePath),
-		loginState:  StateUninitialized,
-		isConnected: nil,
+		storage:    NewStorage(storagePath),
+		loginState: StateUninitialized,
+		root:       storagePath,
+		player:     DefaultPlayerProfile,
 	}
 }
 
+// HasConnection returns whether the server could be reached when last checked
 func (m *Manager) HasConnection() bool {
-	// Only try to connect once
-	if m.isConnected != nil {
-		return m.isConnected()
+	monitor := m.getMonitor()
+	return monitor != nil && monitor.Connected()
+}
+
+// OnLoginStateChange registers fn to be called with every new login state.
+// It may be called from a background goroutine.
+func (m *Manager) OnLoginStateChange(fn func(LoginState)) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+func (m *Manager) setLoginState(state LoginState) {
+	m.stateMu.Lock()
+	if m.loginState == state {
+		m.stateMu.Unlock()
+		return
 	}
+	m.loginState = state
+	listeners := append([]func(LoginState){}, m.listeners...)
+	m.stateMu.Unlock()
 
-	logger.Debug("Checking connection to %s", config.SERVER_ENDPOINT)
-	resp, err := client.Get(fmt.Sprintf("%s/health", config.SERVER_ENDPOINT))
-	if err != nil {
-		logger.Error("Failed to ping server: %v", err)
-		m.isConnected = func() bool { return false }
-		return false
+	for _, fn := range listeners {
+		fn(state)
+	}
+}
+
+func (m *Manager) getMonitor() *Monitor {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return m.monitor
+}
+
+// connect checks the server right away, logging in if it's up,
+// then keeps monitoring it in the background.
+// Does nothing once the monitor was replaced by switching servers or players.
+func (m *Manager) connect(monitor *Monitor) {
+	if monitor.Check() && monitor == m.getMonitor() {
+		if err := m.TryAutoLogin(); err != nil {
+			logger.Debug("Not logged in to %s: %v", m.profile.Name, err)
+		}
 	}
-	defer resp.Body.Close()
 
-	ok := resp.StatusCode == http.StatusOK
-	logger.Debug("Connection status: %v", ok)
-	m.isConnected = func() bool { return ok }
-	return ok
+	if monitor != m.getMonitor() {
+		return
+	}
+	if m.GetLoginState() != StateOnline {
+		m.setLoginState(StateOffline)
+	}
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if monitor == m.monitor {
+		monitor.Start(m.handleConnection)
+	}
+}
+
+// handleConnection moves between login states as the server comes and goes
+func (m *Manager) handleConnection(connected bool) {
+	if !connected {
+		// Keep the session to pick up where it left off
+		if m.GetLoginState() == StateOnline {
+			m.setLoginState(StateReconnecting)
+		}
+		return
+	}
+
+	switch m.GetLoginState() {
+	case StateReconnecting:
+		if err := m.RefreshSession(); err == nil {
+			m.setLoginState(StateOnline)
+			go m.flushScores()
+			return
+		}
+
+		m.endSession()
+		if err := m.TryAutoLogin(); err != nil {
+			logger.Warn("Failed to log in again: %v", err)
+			m.setLoginState(StateOffline)
+		}
+
+	case StateOffline:
+		if err := m.TryAutoLogin(); err != nil {
+			logger.Debug("Not logged in to %s: %v", m.profile.Name, err)
+		}
+	}
 }
 
 // Initialize loads saved state and attempts auto-login
 func (m *Manager) Initialize() error {
+	if err := migrateStorage(legacyStoragePath, m.root); err != nil {
+		logger.Error("Failed to move user data from %s: %v", legacyStoragePath, err)
+	}
+
+	player := loadActivePlayer(m.root)
+	if ValidPlayerName(m.playerOverride) {
+		player = m.playerOverride
+	} else if m.playerOverride != "" {
+		logger.Warn("Invalid player profile name %q, playing as %s", m.playerOverride, player)
+	}
+	if err := m.usePlayer(player); err != nil {
+		return err
+	}
+
+	// Try auto-login if credentials exist
+	m.connect(m.getMonitor())
+	return nil
+}
+
+// SetPlayerOverride picks the player profile for this run, creating it if needed,
+// without changing the one picked on the next launch. Must be called before Initialize.
+func (m *Manager) SetPlayerOverride(name string) {
+	m.playerOverride = name
+}
+
+// GetPlayerProfile returns the name of the player whose data is in use
+func (m *Manager) GetPlayerProfile() string {
+	return m.player
+}
+
+// GetPlayerProfiles lists the player profiles on this machine, the default first
+func (m *Manager) GetPlayerProfiles() []string {
+	return listPlayers(m.root)
+}
+
+// OnPlayerProfileChange registers fn to be called after switching players,
+// from the goroutine that switched
+func (m *Manager) OnPlayerProfileChange(fn func()) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.playerListeners = append(m.playerListeners, fn)
+}
+
+// SwitchPlayerProfile ends the session of the current player and loads the
+// settings of the named one, creating the profile if it doesn't exist.
+// Logging the new player in carries on in the background.
+func (m *Manager) SwitchPlayerProfile(name string) error {
+	if !ValidPlayerName(name) {
+		return fmt.Errorf("invalid player profile name %q", name)
+	}
+	if name == m.player {
+		return nil
+	}
+
+	// Credentials stay stored so switching back logs in again
+	m.setLoginState(StateOffline)
+	m.endSession()
+	if err := m.usePlayer(name); err != nil {
+		return err
+	}
+	if err := saveActivePlayer(m.root, name); err != nil {
+		logger.Error("Failed to save player profile: %v", err)
+	}
+
+	m.stateMu.Lock()
+	listeners := append([]func(){}, m.playerListeners...)
+	m.stateMu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+
+	go m.connect(m.getMonitor())
+	return nil
+}
+
+// usePlayer points storage at a player profile and loads their settings
+func (m *Manager) usePlayer(name string) error {
+	dir := playerDir(m.root, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create player profile: %w", err)
+	}
+	logger.Info("Using player profile %s", name)
+	m.player = name
+	m.storage.SetBasePath(dir)
+
 	// Create default user
 	m.currentUser = &User{
 		Settings: GetDefaultSettings(),
@@ -60,22 +260,95 @@ func (m *Manager) Initialize() error {
 	if settings, err := m.storage.LoadSettings(); err == nil {
 		m.currentUser.Settings = settings
 	}
+	m.saved = m.currentUser.Settings.Clone()
+	m.useProfile(resolveServerProfile(m.currentUser.Settings, m.serverOverride))
+	return nil
+}
 
-	// Try auto-login if credentials exist
-	if err := m.TryAutoLogin(); err == nil {
-		return nil
+// SetServerOverride picks the server for this run by profile name or URL, ahead of settings.
+// Must be called before Initialize.
+func (m *Manager) SetServerOverride(server string) {
+	m.serverOverride = server
+}
+
+// GetServerProfile returns the server being played against
+func (m *Manager) GetServerProfile() ServerProfile {
+	return m.profile
+}
+
+// SwitchServerProfile ends the session with the current server and logs in
+// to the named one with its stored credentials, if there are any
+func (m *Manager) SwitchServerProfile(name string) error {
+	profile, ok := m.currentUser.Settings.GetServerProfile(name)
+	if !ok {
+		return fmt.Errorf("unknown server profile %q", name)
+	}
+
+	// Credentials stay stored so switching back logs in again
+	m.setLoginState(StateOffline)
+	m.endSession()
+	m.currentUser = &User{Settings: m.currentUser.Settings}
+
+	m.currentUser.Settings.ServerProfile = profile.Name
+	if err := m.SaveSettings(); err != nil {
+		logger.Error("Failed to save server profile: %v", err)
 	}
 
-	m.loginState = StateOffline
+	m.useProfile(profile)
+	m.connect(m.getMonitor())
 	return nil
 }
 
-// GetUser returns the current user state
+// useProfile points the client and per-server storage at a profile
+func (m *Manager) useProfile(profile ServerProfile) {
+	logger.Info("Using server %s (%s)", profile.Name, profile.Endpoint)
+	m.profile = profile
+	m.storage.SetProfile(profile.Name)
+	m.storage.client.SetBaseURL(profile.Endpoint)
+
+	m.stateMu.Lock()
+	if m.monitor != nil {
+		m.monitor.Stop()
+	}
+	m.monitor = NewMonitor(profile.Endpoint, client)
+	m.stateMu.Unlock()
+
+	// Load plays that weren't submitted last time
+	outbox, err := m.storage.LoadOutbox()
+	if err != nil {
+		logger.Error("Failed to load outbox: %v", err)
+	}
+	m.outboxMu.Lock()
+	m.outbox = outbox
+	m.outboxMu.Unlock()
+}
+
+// Update applies the changes to the user and settings made in the background since it was last called.
+// Must be called from the game loop.
+func (m *Manager) Update() {
+	m.updatesMu.Lock()
+	updates := m.updates
+	m.updates = nil
+	m.updatesMu.Unlock()
+
+	for _, fn := range updates {
+		fn()
+	}
+}
+
+// later queues fn to run on the game loop, see Update
+func (m *Manager) later(fn func()) {
+	m.updatesMu.Lock()
+	defer m.updatesMu.Unlock()
+	m.updates = append(m.updates, fn)
+}
+
+// GetUser returns the current user state, only changed on the game loop
 func (m *Manager) GetUser() *User {
 	return m.currentUser
 }
 
-// GetSettings returns the current settings
+// GetSettings returns the current settings, only changed on the game loop
 func (m *Manager) GetSettings() *Settings {
 	if m.currentUser == nil {
 		return GetDefaultSettings()
@@ -85,16 +358,66 @@ func (m *Manager) GetSettings() *Settings {
 
 // GetLoginState returns the current login state
 func (m *Manager) GetLoginState() LoginState {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
 	return m.loginState
 }
 
 // GetSession returns the current session if logged in
 func (m *Manager) GetSession() *Session {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
 	return m.session
 }
 
+// startSession hands a new session to the client to keep refreshed
+func (m *Manager) startSession(username string, tokens *TokenPair, remember bool) {
+	m.sessionMu.Lock()
+	m.session = newSession(username, tokens)
+	m.rememberMe = remember
+	m.sessionMu.Unlock()
+
+	m.storage.client.SetTokens(tokens, m.handleTokenRefresh)
+}
+
+// endSession forgets the session locally, leaving stored credentials as they are
+func (m *Manager) endSession() {
+	m.storage.client.SetTokens(nil, nil)
+
+	m.sessionMu.Lock()
+	m.session = nil
+	m.sessionMu.Unlock()
+}
+
+// handleTokenRefresh keeps the session in step with the client after it rotates the tokens
+func (m *Manager) handleTokenRefresh(tokens *TokenPair) {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	if m.session == nil {
+		return
+	}
+	m.session = newSession(m.session.Username, tokens)
+
+	// The stored refresh token was just used up
+	if m.rememberMe {
+		if err := m.storage.SaveCredentials(m.session.Username, tokens.RefreshToken); err != nil {
+			logger.Error("Failed to save credentials: %v", err)
+		}
+	}
+}
+
+// accessToken returns the access token of the current session
+func (m *Manager) accessToken() string {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	if m.session == nil {
+		return ""
+	}
+	return m.session.AccessToken
+}
+
 func (m *Manager) Register(username, password string) error {
-	if m.loginState == StateOnline {
+	if m.GetLoginState() == StateOnline {
 		return fmt.Errorf("already logged in")
 	}
 
@@ -105,67 +428,69 @@ func (m *Manager) Register(username, password string) error {
 	return nil
 }
 
-func (m *Manager) GetLeaderboard(song string, difficulty int) ([]Score, error) {
+// GetLeaderboard returns the top scores of a chart played with a judgement profile
+func (m *Manager) GetLeaderboard(song string, difficulty int, judgement string) ([]Score, error) {
 	if !m.HasConnection() {
 		logger.Debug("No connection available for leaderboard request")
 		return []Score{}, nil
 	}
 	logger.Debug("Fetching leaderboard for song %s difficulty %d", song, difficulty)
-	lb, err := m.storage.client.GetLeaderboard(song, fmt.Sprintf("%d", difficulty))
+	lb, err := m.storage.client.GetLeaderboard(song, fmt.Sprintf("%d", difficulty), judgement, 0, leaderboardSize)
 	if err != nil {
 		logger.Error("Failed to fetch leaderboard: %v", err)
 		return []Score{}, err  // Return the error instead of hiding it
 	}
-	logger.Debug("Retrieved %d leaderboard scores", len(lb))
+	logger.Debug("Retrieved %d of %d leaderboard scores", len(lb.Entries), lb.Total)
+	return lb.Entries, nil
+}
+
+// GetLeaderboardAround returns the leaderboard entries within n places of the user
+func (m *Manager) GetLeaderboardAround(song string, difficulty int, judgement string, n int) (*Leaderboard, error) {
+	if m.GetLoginState() != StateOnline {
+		return nil, nil
+	}
+
+	lb, err := m.storage.client.GetLeaderboardAround(m.accessToken(), song, fmt.Sprintf("%d", difficulty), judgement, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard: %w", err)
+	}
 	return lb, nil
 }
 
-// Login attempts to log in with credentials
+// Login attempts to log in with credentials, must be called from the game loop
 func (m *Manager) Login(username, password string, remember bool) error {
-	m.loginState = StateLoggingIn
-	m.rememberMe = remember
+	m.setLoginState(StateLoggingIn)
 
 	tokens, err := m.storage.client.Login(username, password)
 	if err != nil {
-		m.loginState = StateOffline
+		m.setLoginState(StateOffline)
 		return fmt.Errorf("login failed: %w", err)
 	}
 
 	// Create new session
-	m.session = &Session{
-		Username:     username,
-		AccessToken:  tokens.AccessToken,
-		RefreshToken: tokens.RefreshToken,
-		CreatedAt:    time.Now(),
-		ExpiresAt:    time.Now().Add(24 * time.Hour),
+	m.startSession(username, tokens, remember)
+
+	// Save credentials if remember me enabled
+	if remember {
+		if err := m.storage.SaveCredentials(username, tokens.RefreshToken); err != nil {
+			// Log but don't fail login
+			logger.Error("Failed to save credentials: %v\n", err)
+		}
 	}
 
 	// Get user
 	user, err := m.storage.client.GetUser(tokens.AccessToken)
 	if err != nil {
-		m.loginState = StateOffline
+		m.setLoginState(StateOffline)
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
 	user.Settings = m.currentUser.Settings
 	m.currentUser = user
-	m.loginState = StateOnline
-
-	// Save credentials if remember me enabled
-	if remember {
-		if err := m.storage.SaveCredentials(username, tokens.RefreshToken); err != nil {
-			// Log but don't fail login
-			logger.Error("Failed to save credentials: %v\n", err)
-		}
-	}
+	m.setLoginState(StateOnline)
 
-	// // Sync settings
-	// if user.Settings != nil {
-	// 	m.currentUser.Settings.MergeFrom(user.Settings)
-	// 	if err := m.storage.SaveSettings(m.currentUser.Settings); err != nil {
-	// 		fmt.Printf("Failed to save synced settings: %v\n", err)
-	// 	}
-	// }
+	m.syncSettings()
+	go m.flushScores()
 
 	return nil
 }
@@ -178,17 +503,20 @@ func (m *Manager) TryAutoLogin() error {
 	}
 
 	tokens, err := m.storage.client.Refresh(creds.RefreshToken)
-	if err != nil {
+	if IsRejected(err) || hasStatus(err, http.StatusUnauthorized) {
 		m.storage.ClearCredentials()
 		return fmt.Errorf("stored credentials expired")
 	}
+	if err != nil {
+		// Keep the credentials to try again once the server is back
+		return fmt.Errorf("failed to refresh session: %w", err)
+	}
+	m.startSession(creds.Username, tokens, true)
 
-	m.session = &Session{
-		Username:     creds.Username,
-		AccessToken:  tokens.AccessToken,
-		RefreshToken: tokens.RefreshToken,
-		CreatedAt:    time.Now(),
-		ExpiresAt:    time.Now().Add(24 * time.Hour),
+	// The stored refresh token was just used up
+	if err := m.storage.SaveCredentials(creds.Username, tokens.RefreshToken); err != nil {
+		// Log but don't fail login
+		logger.Error("Failed to save credentials: %v\n", err)
 	}
 
 	user, err := m.storage.client.GetUser(tokens.AccessToken)
@@ -196,21 +524,18 @@ func (m *Manager) TryAutoLogin() error {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
-	user.Settings = m.currentUser.Settings
-	m.currentUser = user
-	m.loginState = StateOnline
-	if err := m.storage.SaveCredentials(user.Username, tokens.RefreshToken); err != nil {
-		// Log but don't fail login
-		logger.Error("Failed to save credentials: %v\n", err)
-	}
-	// Sync settings
-	// if user.Settings != nil {
-	// 	m.currentUser.Settings.MergeFrom(user.Settings)
-	// 	if err := m.storage.SaveSettings(m.currentUser.Settings); err != nil {
-	// 		fmt.Printf("Failed to save synced settings: %v\n", err)
-	// 	}
-	// }
-	logger.Debug("Auto logged in as %s", m.currentUser.Username)
+	// Usually run from the monitor, the user is swapped in on the game loop
+	m.setLoginState(StateOnline)
+	m.later(func() {
+		if session := m.GetSession(); session == nil || session.Username != creds.Username {
+			return
+		}
+		user.Settings = m.currentUser.Settings
+		m.currentUser = user
+		m.syncSettings()
+	})
+	go m.flushScores()
+	logger.Debug("Auto logged in as %s", user.Username)
 
 	return nil
 }
@@ -219,26 +544,25 @@ func (m *Manager) TryAutoLogin() error {
 func (m *Manager) Logout() {
 	m.storage.ClearCredentials()
 
-	if m.session != nil {
-		m.session = nil
+	if accessToken := m.accessToken(); accessToken != "" {
+		// Don't hold up the UI on the server, the local session is gone either way
+		go func() {
+			if err := m.storage.client.Logout(accessToken); err != nil {
+				logger.Warn("Failed to end server session: %v", err)
+			}
+		}()
+		m.endSession()
 	}
 
-	m.loginState = StateOffline
+	m.setLoginState(StateOffline)
 }
 
+// RefreshSession rotates the tokens of the current session,
+// requests otherwise only do so once the access token has expired
 func (m *Manager) RefreshSession() error {
-	if m.session == nil {
-		return fmt.Errorf("no session to refresh")
-	}
-
-	tokens, err := m.storage.client.Refresh(m.session.RefreshToken)
-	if err != nil {
+	if _, err := m.storage.client.RefreshTokens(); err != nil {
 		return fmt.Errorf("failed to refresh session: %w", err)
 	}
-
-	m.session.AccessToken = tokens.AccessToken
-	m.session.RefreshToken = tokens.RefreshToken
-	m.session.ExpiresAt = time.Now().Add(24 * time.Hour)
 	return nil
 }
 
@@ -249,68 +573,332 @@ func (m *Manager) SaveSettings() error {
 	}
 
 	// Update modification time
-	m.currentUser.Settings.LastModified = time.Now()
+	now := time.Now()
+	settings := m.currentUser.Settings
+	settings.LastModified = now
+	settings.MarkChanged(m.saved, now)
 
 	// Save locally
-	if err := m.storage.SaveSettings(m.currentUser.Settings); err != nil {
+	if err := m.storage.SaveSettings(settings); err != nil {
 		return fmt.Errorf("failed to save settings: %w", err)
 	}
+	m.saved = settings.Clone()
 
-	// // Sync to server if logged in
-	// if m.loginState == StateOnline {
-	// 	if err := m.storage.client.UpdateUser(m.session.AccessToken, m.currentUser.Settings); err != nil {
-	// 		// Log but don't fail local save
-	// 		fmt.Printf("Failed to sync settings to server: %v\n", err)
-	// 	} else {
-	// 		m.currentUser.Settings.LastSync = time.Now()
-	// 	}
-	// }
+	// Push to server if logged in, without holding up the UI.
+	// Anything newer on the server is picked up on the next login.
+	if m.GetLoginState() == StateOnline {
+		accessToken := m.accessToken()
+		values := settings.SyncedValues()
+		go func() {
+			if _, err := m.storage.client.SyncSettings(accessToken, values); err != nil {
+				// Log but don't fail local save
+				logger.Warn("Failed to sync settings to server: %v", err)
+			}
+		}()
+	}
 
 	return nil
 }
 
+// syncSettings merges the local settings with the account's,
+// each setting taking whichever side changed it last.
+// Must be called from the game loop, the account's settings are applied on it by Update.
+func (m *Manager) syncSettings() {
+	settings := m.currentUser.Settings
+	accessToken := m.accessToken()
+	values := settings.SyncedValues()
+	go func() {
+		remote, err := m.storage.client.SyncSettings(accessToken, values)
+		if err != nil {
+			logger.Warn("Failed to sync settings: %v", err)
+			return
+		}
+
+		m.later(func() {
+			// Switched players meanwhile
+			if m.currentUser.Settings != settings {
+				return
+			}
+
+			if settings.ApplySynced(remote) {
+				logger.Debug("Applied synced settings from server")
+				settings.Validate()
+			}
+			settings.LastSync = time.Now()
+			if err := m.storage.SaveSettings(settings); err != nil {
+				logger.Error("Failed to save synced settings: %v", err)
+			}
+			m.saved = settings.Clone()
+		})
+	}()
+}
+
+// NewPlayID returns an ID for a finished play, sent along with it so the server stores it only once
+func NewPlayID() (string, error) {
+	id, err := generatePlayID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate play id: %w", err)
+	}
+	return id, nil
+}
+
+// AddScore queues a finished play for submission, sending it right away if logged in.
+// Queued plays are kept on disk until the server has them.
 func (m *Manager) AddScore(s *Score) error {
-	if m.loginState != StateOnline {
-		return nil
+	if s.PlayID == "" {
+		id, err := NewPlayID()
+		if err != nil {
+			return err
+		}
+		s.PlayID = id
+	}
+
+	m.outboxMu.Lock()
+	m.outbox = append(m.outbox, *s)
+	err := m.storage.SaveOutbox(m.outbox)
+	m.outboxMu.Unlock()
+	if err != nil {
+		logger.Error("Failed to save outbox: %v", err)
+	}
+
+	if m.GetLoginState() == StateOnline {
+		go m.flushScores()
+	}
+	return nil
+}
+
+// PendingScores returns how many plays are waiting to be submitted
+func (m *Manager) PendingScores() int {
+	m.outboxMu.Lock()
+	defer m.outboxMu.Unlock()
+	pending := 0
+	for _, score := range m.outbox {
+		if score.Rejected == "" {
+			pending++
+		}
+	}
+	return pending
+}
+
+// RejectedScores returns the plays the server refused, kept so they can be shown locally
+func (m *Manager) RejectedScores() []Score {
+	m.outboxMu.Lock()
+	defer m.outboxMu.Unlock()
+	rejected := make([]Score, 0)
+	for _, score := range m.outbox {
+		if score.Rejected != "" {
+			rejected = append(rejected, score)
+		}
+	}
+	return rejected
+}
+
+// nextScore returns the first play in the outbox that is still to be submitted.
+// Must be called with m.outboxMu held.
+func (m *Manager) nextScore() (Score, bool) {
+	for _, score := range m.outbox {
+		if score.Rejected == "" {
+			return score, true
+		}
+	}
+	return Score{}, false
+}
+
+// flushScores submits queued plays in order while logged in,
+// backing off whenever the server can't be reached.
+// Plays the server refuses stay in the outbox marked as rejected,
+// and the session ends if the server no longer accepts it.
+func (m *Manager) flushScores() {
+	m.outboxMu.Lock()
+	if m.flushing {
+		m.outboxMu.Unlock()
+		return
+	}
+	m.flushing = true
+	m.outboxMu.Unlock()
+
+	defer func() {
+		m.outboxMu.Lock()
+		m.flushing = false
+		m.outboxMu.Unlock()
+	}()
+
+	submitted := 0
+	delay := outboxRetryDelay
+	for m.GetLoginState() == StateOnline {
+		m.outboxMu.Lock()
+		score, ok := m.nextScore()
+		m.outboxMu.Unlock()
+		if !ok {
+			break
+		}
+
+		err := m.storage.client.AddScore(m.accessToken(), &score)
+		if hasStatus(err, http.StatusUnauthorized) {
+			// The client already failed to refresh the tokens, the session is gone
+			logger.Warn("Session expired while submitting play %s: %v", score.PlayID, err)
+			m.setLoginState(StateOffline)
+			m.endSession()
+			return
+		}
+		if err != nil && !IsRejected(err) {
+			logger.Warn("Failed to submit play %s, retrying in %v: %v", score.PlayID, delay, err)
+			time.Sleep(delay)
+			delay = min(delay*2, outboxMaxRetryDelay)
+			continue
+		}
+		if err != nil {
+			logger.Error("Server rejected play %s: %v", score.PlayID, err)
+		} else {
+			submitted++
+		}
+		delay = outboxRetryDelay
+
+		// The outbox may have been swapped for another server profile's meanwhile
+		var saveErr error
+		m.outboxMu.Lock()
+		for i := range m.outbox {
+			if m.outbox[i].PlayID != score.PlayID {
+				continue
+			}
+			if err != nil {
+				m.outbox[i].Rejected = err.Error()
+			} else {
+				m.outbox = append(m.outbox[:i], m.outbox[i+1:]...)
+			}
+			saveErr = m.storage.SaveOutbox(m.outbox)
+			break
+		}
+		m.outboxMu.Unlock()
+		if saveErr != nil {
+			logger.Error("Failed to save outbox: %v", saveErr)
+		}
 	}
 
-	if err := m.storage.client.AddScore(m.session.AccessToken, s); err != nil {
-		return fmt.Errorf("failed to add score: %w", err)
+	if submitted == 0 || m.GetLoginState() != StateOnline {
+		return
 	}
 
 	// Refetch user rank after
-	user, err := m.storage.client.GetUser(m.session.AccessToken)
+	user, err := m.storage.client.GetUser(m.accessToken())
 	if err != nil {
 		logger.Debug("Failed to get user: %v", err)
-		return fmt.Errorf("failed to get user: %w", err)
+		return
 	}
 
-	m.currentUser.Rank = user.Rank
-	logger.Debug("Updated user rank from %d to %d", m.currentUser.Rank, user.Rank)
-	return nil
+	m.later(func() {
+		if m.currentUser.Username != user.Username {
+			return
+		}
+		logger.Debug("Updated user rank from %.2f to %.2f", m.currentUser.Rank, user.Rank)
+		m.currentUser.Rank = user.Rank
+	})
 }
 
-func (m *Manager) GetScore(songHash string, difficulty int) (*Score, error) {
-	if m.loginState != StateOnline {
+// newSession creates a session for freshly issued tokens
+func newSession(username string, tokens *TokenPair) *Session {
+	now := time.Now()
+	return &Session{
+		Username:     username,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		CreatedAt:    now,
+		ExpiresAt:    tokenExpiry(tokens.AccessToken, now.Add(24*time.Hour)),
+	}
+}
+
+// tokenExpiry reads when an access token expires from its claims,
+// the server checks the signature so it isn't verified here
+func tokenExpiry(token string, fallback time.Time) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return fallback
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+func generatePlayID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetScore returns the best score of the user on a chart with a judgement profile
+func (m *Manager) GetScore(songHash string, difficulty int, judgement string) (*Score, error) {
+	if m.GetLoginState() != StateOnline {
 		return nil, nil
 	}
 
-	s, err := m.storage.client.GetUserScores(m.session.AccessToken)
+	s, err := m.storage.client.GetUserScores(m.accessToken())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get score: %w", err)
 	}
 	for _, score := range s {
-		if score.SongHash == songHash && score.Difficulty == difficulty {
+		if score.SongHash == songHash && score.Difficulty == difficulty && judge.JudgementID(score.Judgement) == judgement {
 			return &score, nil
 		}
 	}
 	return nil, nil
 }
 
+// AddPlay records a finished play in the local history
+func (m *Manager) AddPlay(p *PlayRecord) error {
+	if err := m.storage.history.Add(*p); err != nil {
+		return fmt.Errorf("failed to add play: %w", err)
+	}
+	return nil
+}
+
+// SaveReplay keeps the replay of a play, returning the path of its file
+func (m *Manager) SaveReplay(replay *judge.Replay) (string, error) {
+	return m.storage.SaveReplay(replay, time.Now())
+}
+
+// GetBestPlay returns the local personal best of a chart with a judgement profile,
+// nil if it was never played with it
+func (m *Manager) GetBestPlay(songHash string, difficulty int, judgement string) *PlayRecord {
+	return m.storage.history.Best(songHash, difficulty, judgement)
+}
+
+// GetRecentPlays returns the last n local plays, most recent first,
+// with the reason of those the server rejected
+func (m *Manager) GetRecentPlays(n int) []PlayRecord {
+	plays := m.storage.history.Recent(n)
+
+	m.outboxMu.Lock()
+	defer m.outboxMu.Unlock()
+	for i := range plays {
+		for _, score := range m.outbox {
+			if plays[i].PlayID != "" && score.PlayID == plays[i].PlayID {
+				plays[i].Rejected = score.Rejected
+			}
+		}
+	}
+	return plays
+}
+
+// GetPlayCount returns how many times a chart was played on this machine
+func (m *Manager) GetPlayCount(songHash string, difficulty int) int {
+	return m.storage.history.PlayCount(songHash, difficulty)
+}
+
 // GetDefaultSettings returns default settings values
 func GetDefaultSettings() *Settings {
 	return &Settings{
-		Version:            1,
+		Version:            SettingsVersion,
 		LastModified:       time.Now(),
 		Locale:             "en-us",
 		Fullscreen:         false,
@@ -329,6 +917,7 @@ func GetDefaultSettings() *Settings {
 		CenterNoteColor:    "#e6e600ff",
 		CornerNoteColor:    "#e68200ff",
 		DisableHoldNotes:   false,
+		Judgement:          judge.Standard.Name,
 		DisableHitEffects:  false,
 		DisableLaneEffects: false,
 		Use3DNotes:         true, // Default to 3D rendering
@@ -0,0 +1,90 @@
+package external
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newScoreServer accepts every play but "rejected", or no request at all once expired
+func newScoreServer(t *testing.T, expired *atomic.Bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if expired.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/scores":
+			var score Score
+			json.NewDecoder(r.Body).Decode(&score)
+			if score.PlayID == "rejected" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(score)
+		case "/user":
+			json.NewEncoder(w).Encode(User{Username: "slapper"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newOnlineManager is logged in to the server with plays queued under the given IDs
+func newOnlineManager(t *testing.T, url string, plays ...string) *Manager {
+	m := NewManager(t.TempDir())
+	m.storage.client = testClient(url)
+	for _, id := range plays {
+		if err := m.AddScore(&Score{PlayID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.startSession("slapper", &TokenPair{AccessToken: "access", RefreshToken: "refresh"}, false)
+	m.setLoginState(StateOnline)
+	return m
+}
+
+func TestFlushScoresKeepsRejected(t *testing.T) {
+	var expired atomic.Bool
+	server := newScoreServer(t, &expired)
+	m := newOnlineManager(t, server.URL, "first", "rejected", "last")
+
+	m.flushScores()
+	if pending := m.PendingScores(); pending != 0 {
+		t.Errorf("expected every play to be sent, %d pending", pending)
+	}
+	rejected := m.RejectedScores()
+	if len(rejected) != 1 || rejected[0].PlayID != "rejected" || rejected[0].Rejected == "" {
+		t.Fatalf("expected the rejected play to be kept, got %+v", rejected)
+	}
+
+	// Kept across restarts
+	outbox, err := m.storage.LoadOutbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 1 || outbox[0].Rejected == "" {
+		t.Errorf("expected the rejected play to be saved, got %+v", outbox)
+	}
+}
+
+func TestFlushScoresEndsExpiredSession(t *testing.T) {
+	var expired atomic.Bool
+	expired.Store(true)
+	server := newScoreServer(t, &expired)
+	m := newOnlineManager(t, server.URL, "first")
+
+	// Returns instead of retrying a session the server no longer accepts
+	m.flushScores()
+	if m.GetLoginState() != StateOffline || m.GetSession() != nil {
+		t.Errorf("expected the session to end, got %v with %+v", m.GetLoginState(), m.GetSession())
+	}
+	if pending := m.PendingScores(); pending != 1 {
+		t.Errorf("expected the play to wait for the next session, %d pending", pending)
+	}
+}
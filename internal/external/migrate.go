@@ -0,0 +1,138 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+// SettingsVersion is the version of the settings written by this build.
+// Bump it along with a new entry in settingsMigrations whenever a setting
+// is renamed or changes meaning.
+const SettingsVersion = 2
+
+// settingsMigrations upgrade raw settings one version at a time,
+// the first taking version 1 to 2
+var settingsMigrations = []func(raw map[string]json.RawMessage) error{
+	// 1 -> 2: the edge play area was stored under its old name
+	func(raw map[string]json.RawMessage) error {
+		renameSetting(raw, "fullscreen_play_area", "edge_play_area")
+		return nil
+	},
+}
+
+// Ranges settings are kept within
+const (
+	minNoteWidth = 0.5
+	maxNoteWidth = 2.0
+	minLaneSpeed = 0.5
+	maxLaneSpeed = 10.0
+	maxOffset    = 1500 // ms, as far as the offset screen goes
+)
+
+// migrateSettings brings raw settings up to SettingsVersion,
+// returning the version they were at
+func migrateSettings(raw map[string]json.RawMessage) (int, error) {
+	version := 1
+	if v, ok := raw["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return 0, fmt.Errorf("invalid settings version: %w", err)
+		}
+	}
+	if version < 1 {
+		version = 1
+	}
+
+	from := version
+	for ; version < SettingsVersion; version++ {
+		if version-1 >= len(settingsMigrations) {
+			return from, fmt.Errorf("no migration from settings version %d", version)
+		}
+		if err := settingsMigrations[version-1](raw); err != nil {
+			return from, fmt.Errorf("failed to migrate settings from version %d: %w", version, err)
+		}
+	}
+
+	if from < SettingsVersion {
+		raw["version"], _ = json.Marshal(SettingsVersion)
+	}
+	return from, nil
+}
+
+func renameSetting(raw map[string]json.RawMessage, from, to string) {
+	v, ok := raw[from]
+	if !ok {
+		return
+	}
+	delete(raw, from)
+	if _, ok := raw[to]; !ok {
+		raw[to] = v
+	}
+}
+
+// Validate puts every setting back in range, falling back to the default
+// for anything that makes no sense
+func (s *Settings) Validate() {
+	defaults := GetDefaultSettings()
+
+	s.BGMVolume = clampSetting(s.BGMVolume, 0, 1, defaults.BGMVolume)
+	s.SFXVolume = clampSetting(s.SFXVolume, 0, 1, defaults.SFXVolume)
+	s.SongVolume = clampSetting(s.SongVolume, 0, 1, defaults.SongVolume)
+
+	// Zero is never a usable size or speed, it's what a missing setting decodes to
+	if s.NoteWidth <= 0 {
+		s.NoteWidth = defaults.NoteWidth
+	}
+	s.NoteWidth = float32(clampSetting(float64(s.NoteWidth), minNoteWidth, maxNoteWidth, float64(defaults.NoteWidth)))
+	if s.LaneSpeed <= 0 {
+		s.LaneSpeed = defaults.LaneSpeed
+	}
+	s.LaneSpeed = clampSetting(s.LaneSpeed, minLaneSpeed, maxLaneSpeed, defaults.LaneSpeed)
+
+	s.AudioOffset = max(-maxOffset, min(s.AudioOffset, maxOffset))
+	s.InputOffset = max(-maxOffset, min(s.InputOffset, maxOffset))
+
+	if s.ScreenWidth <= 0 || s.ScreenHeight <= 0 {
+		s.ScreenWidth, s.ScreenHeight = defaults.ScreenWidth, defaults.ScreenHeight
+	}
+	if s.RenderWidth <= 0 || s.RenderHeight <= 0 {
+		s.RenderWidth, s.RenderHeight = defaults.RenderWidth, defaults.RenderHeight
+	}
+	if s.KeyConfig < 0 {
+		s.KeyConfig = defaults.KeyConfig
+	}
+	if s.Locale == "" {
+		s.Locale = defaults.Locale
+	}
+	if s.NoteColorTheme == "" {
+		s.NoteColorTheme = defaults.NoteColorTheme
+	}
+	if s.CenterNoteColor == "" {
+		s.CenterNoteColor = defaults.CenterNoteColor
+	}
+	if s.CornerNoteColor == "" {
+		s.CornerNoteColor = defaults.CornerNoteColor
+	}
+
+	if s.Modifiers.Validate() != nil {
+		s.Modifiers = defaults.Modifiers
+	}
+
+	// Judged with a profile that exists, see JudgementProfile
+	if s.Judgement == judge.CustomProfile {
+		if s.CustomJudgement == nil || s.CustomJudgement.Validate() != nil {
+			s.Judgement = defaults.Judgement
+		}
+	} else if judge.GetProfile(s.Judgement).Name != s.Judgement {
+		s.Judgement = defaults.Judgement
+	}
+}
+
+func clampSetting(v, lo, hi, fallback float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fallback
+	}
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,101 @@
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+func TestLoadSettingsMigrates(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	path := filepath.Join(s.basePath, settingsFilename)
+
+	// As written by version 1, from before lane speed was saved
+	v1 := `{"version": 1, "locale": "ja-jp", "fullscreen_play_area": true, "bgm_volume": 0.2}`
+	if err := os.WriteFile(path, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := s.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Version != SettingsVersion {
+		t.Fatalf("expected version %d, got %d", SettingsVersion, settings.Version)
+	}
+	if !settings.EdgePlayArea {
+		t.Fatal("renamed setting was lost")
+	}
+	if settings.Locale != "ja-jp" || settings.BGMVolume != 0.2 {
+		t.Fatalf("settings not kept: %+v", settings)
+	}
+	if settings.LaneSpeed != GetDefaultSettings().LaneSpeed {
+		t.Fatalf("missing setting decoded as %v instead of the default", settings.LaneSpeed)
+	}
+
+	backup, err := os.ReadFile(path + ".v1.bak")
+	if err != nil || string(backup) != v1 {
+		t.Fatalf("settings not backed up: %q %v", backup, err)
+	}
+
+	// Written back upgraded
+	again, err := s.LoadSettings()
+	if err != nil || !again.EdgePlayArea || again.Version != SettingsVersion {
+		t.Fatalf("migrated settings not saved: %+v %v", again, err)
+	}
+}
+
+func TestLoadSettingsFromNewerBuild(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	path := filepath.Join(s.basePath, settingsFilename)
+	if err := os.WriteFile(path, []byte(`{"version": 99, "edge_play_area": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := s.LoadSettings()
+	if err != nil || !settings.EdgePlayArea {
+		t.Fatalf("failed to load settings: %+v %v", settings, err)
+	}
+	if _, err := os.Stat(path + ".v99.bak"); !os.IsNotExist(err) {
+		t.Fatal("backed up settings that weren't migrated")
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	defaults := GetDefaultSettings()
+	tests := []struct {
+		name  string
+		edit  func(*Settings)
+		check func(*Settings) bool
+	}{
+		{"volume above max", func(s *Settings) { s.SongVolume = 3 }, func(s *Settings) bool { return s.SongVolume == 1 }},
+		{"negative volume", func(s *Settings) { s.SFXVolume = -1 }, func(s *Settings) bool { return s.SFXVolume == 0 }},
+		{"zero lane speed", func(s *Settings) { s.LaneSpeed = 0 }, func(s *Settings) bool { return s.LaneSpeed == defaults.LaneSpeed }},
+		{"lane speed above max", func(s *Settings) { s.LaneSpeed = 50 }, func(s *Settings) bool { return s.LaneSpeed == maxLaneSpeed }},
+		{"zero note width", func(s *Settings) { s.NoteWidth = 0 }, func(s *Settings) bool { return s.NoteWidth == defaults.NoteWidth }},
+		{"note width below min", func(s *Settings) { s.NoteWidth = 0.1 }, func(s *Settings) bool { return s.NoteWidth == minNoteWidth }},
+		{"audio offset too late", func(s *Settings) { s.AudioOffset = 5000 }, func(s *Settings) bool { return s.AudioOffset == maxOffset }},
+		{"input offset too early", func(s *Settings) { s.InputOffset = -5000 }, func(s *Settings) bool { return s.InputOffset == -maxOffset }},
+		{"in range", func(s *Settings) { s.LaneSpeed = 2.5 }, func(s *Settings) bool { return s.LaneSpeed == 2.5 }},
+		{"unknown modifiers", func(s *Settings) { s.Modifiers = judge.ModMirror | 1<<31 }, func(s *Settings) bool { return s.Modifiers == defaults.Modifiers }},
+		{"unknown judgement", func(s *Settings) { s.Judgement = "easy" }, func(s *Settings) bool { return s.Judgement == defaults.Judgement }},
+		{"custom judgement without a table", func(s *Settings) { s.Judgement = judge.CustomProfile }, func(s *Settings) bool { return s.Judgement == defaults.Judgement }},
+		{"custom judgement", func(s *Settings) {
+			s.Judgement = judge.CustomProfile
+			s.CustomJudgement = &judge.Profile{Slap: judge.Window{Early: 30, Late: 50}, SlapValue: 1}
+		}, func(s *Settings) bool { return s.JudgementProfile().ID() != judge.Standard.ID() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := GetDefaultSettings()
+			tt.edit(s)
+			s.Validate()
+			if !tt.check(s) {
+				t.Fatalf("not put back in range: %+v", s)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package external
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
+// How often the server is checked, backing off while it can't be reached
+var (
+	monitorInterval   = 30 * time.Second
+	monitorMinBackoff = 2 * time.Second
+	monitorMaxBackoff = 2 * time.Minute
+)
+
+// Monitor keeps track of whether the server can be reached,
+// checking its health endpoint in the background
+type Monitor struct {
+	endpoint   string
+	client     *http.Client
+	interval   time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu        sync.Mutex
+	connected bool
+	onChange  func(connected bool)
+	stop      chan struct{}
+}
+
+// NewMonitor creates a monitor for the server at endpoint, it is offline until checked
+func NewMonitor(endpoint string, client *http.Client) *Monitor {
+	return &Monitor{
+		endpoint:   endpoint,
+		client:     client,
+		interval:   monitorInterval,
+		minBackoff: monitorMinBackoff,
+		maxBackoff: monitorMaxBackoff,
+	}
+}
+
+// Connected returns whether the server could be reached on the last check
+func (m *Monitor) Connected() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.connected
+}
+
+// Check pings the server now, reporting whether it could be reached
+func (m *Monitor) Check() bool {
+	connected := m.ping()
+
+	m.mu.Lock()
+	changed := connected != m.connected
+	m.connected = connected
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	if changed {
+		logger.Debug("Connection to %s: %v", m.endpoint, connected)
+		if onChange != nil {
+			onChange(connected)
+		}
+	}
+	return connected
+}
+
+// Start checks the server in the background until Stop,
+// calling onChange from the monitor goroutine whenever it comes or goes
+func (m *Monitor) Start(onChange func(connected bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return
+	}
+
+	m.onChange = onChange
+	m.stop = make(chan struct{})
+	go m.run(m.stop, m.connected)
+}
+
+// Stop ends background checks
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop == nil {
+		return
+	}
+
+	close(m.stop)
+	m.stop = nil
+	m.onChange = nil
+}
+
+func (m *Monitor) run(stop chan struct{}, connected bool) {
+	delay := m.interval
+	if !connected {
+		delay = m.minBackoff
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+
+		switch {
+		case m.Check():
+			delay = m.interval
+		case connected:
+			// Just went down, retry soon
+			delay = m.minBackoff
+		default:
+			delay = min(delay*2, m.maxBackoff)
+		}
+		connected = m.Connected()
+	}
+}
+
+func (m *Monitor) ping() bool {
+	resp, err := m.client.Get(fmt.Sprintf("%s/health", m.endpoint))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
@@ -0,0 +1,243 @@
+package external
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// standIn is a minimal stand-in for the service that can be taken down and brought back
+type standIn struct {
+	*httptest.Server
+	up        atomic.Bool
+	refreshes atomic.Int32
+}
+
+func newStandIn(t *testing.T, up bool) *standIn {
+	s := &standIn{}
+	s.up.Store(up)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/refresh":
+			s.refreshes.Add(1)
+			json.NewEncoder(w).Encode(TokenPair{AccessToken: "access", RefreshToken: "refresh"})
+		case "/user":
+			json.NewEncoder(w).Encode(User{Username: "slapper", Rank: 12})
+		case "/user/settings":
+			json.NewEncoder(w).Encode(map[string]SettingValue{})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func fastMonitor(t *testing.T) {
+	interval, minBackoff, maxBackoff := monitorInterval, monitorMinBackoff, monitorMaxBackoff
+	monitorInterval = 20 * time.Millisecond
+	monitorMinBackoff = 5 * time.Millisecond
+	monitorMaxBackoff = 20 * time.Millisecond
+	retryDelay := requestRetryDelay
+	requestRetryDelay = time.Millisecond
+	t.Cleanup(func() {
+		monitorInterval, monitorMinBackoff, monitorMaxBackoff = interval, minBackoff, maxBackoff
+		requestRetryDelay = retryDelay
+	})
+}
+
+func waitFor[T comparable](t *testing.T, ch <-chan T, want T) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case got := <-ch:
+			if got == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+}
+
+func TestMonitorCheck(t *testing.T) {
+	server := newStandIn(t, true)
+	m := NewMonitor(server.URL, server.Client())
+
+	if m.Connected() {
+		t.Fatal("monitor connected before the first check")
+	}
+	if !m.Check() || !m.Connected() {
+		t.Fatal("server is up but monitor is offline")
+	}
+
+	server.up.Store(false)
+	if m.Check() || m.Connected() {
+		t.Fatal("server is down but monitor is online")
+	}
+}
+
+func TestMonitorUnreachable(t *testing.T) {
+	server := newStandIn(t, true)
+	server.Close()
+
+	m := NewMonitor(server.URL, server.Client())
+	if m.Check() {
+		t.Fatal("closed server reported as reachable")
+	}
+}
+
+func TestMonitorPublishesChanges(t *testing.T) {
+	fastMonitor(t)
+	server := newStandIn(t, false)
+
+	m := NewMonitor(server.URL, server.Client())
+	changes := make(chan bool, 16)
+	m.Start(func(connected bool) { changes <- connected })
+	defer m.Stop()
+
+	server.up.Store(true)
+	waitFor(t, changes, true)
+
+	server.up.Store(false)
+	waitFor(t, changes, false)
+
+	server.up.Store(true)
+	waitFor(t, changes, true)
+}
+
+func TestMonitorStop(t *testing.T) {
+	fastMonitor(t)
+	server := newStandIn(t, false)
+
+	m := NewMonitor(server.URL, server.Client())
+	changes := make(chan bool, 16)
+	m.Start(func(connected bool) { changes <- connected })
+	m.Stop()
+	m.Stop()
+
+	server.up.Store(true)
+	select {
+	case <-changes:
+		t.Fatal("stopped monitor published a change")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestManagerReconnects(t *testing.T) {
+	fastMonitor(t)
+	server := newStandIn(t, false)
+
+	// Credentials are stored per server, named after the host when given by URL
+	m := NewManager(t.TempDir())
+	m.SetServerOverride(server.URL)
+	m.storage.SetProfile(server.Listener.Addr().String())
+	if err := m.storage.SaveCredentials("slapper", "stored"); err != nil {
+		t.Fatal(err)
+	}
+
+	states := make(chan LoginState, 16)
+	m.OnLoginStateChange(func(state LoginState) { states <- state })
+
+	// Launched while the server is down
+	if err := m.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.getMonitor().Stop)
+	waitFor(t, states, StateOffline)
+
+	// Logs in with the stored credentials once it's back
+	server.up.Store(true)
+	waitFor(t, states, StateOnline)
+	if server.refreshes.Load() != 1 {
+		t.Fatalf("expected 1 refresh, got %d", server.refreshes.Load())
+	}
+
+	// Keeps the session through an outage
+	server.up.Store(false)
+	waitFor(t, states, StateReconnecting)
+
+	server.up.Store(true)
+	waitFor(t, states, StateOnline)
+	if server.refreshes.Load() != 2 {
+		t.Fatalf("expected 2 refreshes, got %d", server.refreshes.Load())
+	}
+
+	creds, err := m.storage.LoadCredentials()
+	if err != nil || creds == nil {
+		t.Fatalf("credentials lost: %v", err)
+	}
+}
+
+func TestManagerKeepsCredentialsWhileDown(t *testing.T) {
+	server := newStandIn(t, true)
+
+	m := NewManager(t.TempDir())
+	m.storage.client.SetBaseURL(server.URL)
+	if err := m.storage.SaveCredentials("slapper", "stored"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.up.Store(false)
+	if err := m.TryAutoLogin(); err == nil {
+		t.Fatal("logged in while the server is down")
+	}
+
+	creds, err := m.storage.LoadCredentials()
+	if err != nil || creds == nil {
+		t.Fatalf("credentials cleared by an outage: %v", err)
+	}
+}
+
+func TestManagerAppliesLoginOnUpdate(t *testing.T) {
+	server := newStandIn(t, true)
+
+	m := NewManager(t.TempDir())
+	m.SetServerOverride(server.URL)
+	m.storage.SetProfile(server.Listener.Addr().String())
+	if err := m.storage.SaveCredentials("slapper", "stored"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.getMonitor().Stop)
+
+	// Logged in from the background, the game still sees the old user
+	if m.GetLoginState() != StateOnline {
+		t.Fatalf("expected to be online, got %v", m.GetLoginState())
+	}
+	if m.GetUser().Username != "" {
+		t.Fatalf("expected the user to change on the game loop, got %q", m.GetUser().Username)
+	}
+
+	settings := m.GetSettings()
+	m.Update()
+	if user := m.GetUser(); user.Username != "slapper" || user.Rank != 12 {
+		t.Fatalf("expected slapper at rank 12, got %+v", user)
+	}
+	if m.GetSettings() != settings {
+		t.Fatal("expected the local settings to be kept")
+	}
+
+	// The account's settings arrive on a later update
+	deadline := time.Now().Add(2 * time.Second)
+	for settings.LastSync.IsZero() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		m.Update()
+	}
+	if settings.LastSync.IsZero() {
+		t.Fatal("timed out waiting for settings to sync")
+	}
+}
@@ -0,0 +1,98 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Each player on a shared machine keeps their own settings, credentials and history.
+// The default player uses the data directory itself, so data from before
+// profiles existed stays with it, others live under profiles/<name>.
+const (
+	DefaultPlayerProfile = "default"
+
+	playerProfilesDir = "profiles"
+	playerFilename    = "player.json"
+	MaxPlayerNameLen  = 16
+)
+
+// ValidPlayerName reports whether name can be used for a player profile.
+// Names double as folder names, so only letters, digits, - and _ are allowed.
+func ValidPlayerName(name string) bool {
+	if name == "" || len(name) > MaxPlayerNameLen {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// playerDir returns where the data of a player profile is kept
+func playerDir(root, name string) string {
+	if name == "" || name == DefaultPlayerProfile {
+		return root
+	}
+	return filepath.Join(root, playerProfilesDir, name)
+}
+
+// listPlayers returns the default player followed by every other profile by name
+func listPlayers(root string) []string {
+	players := []string{DefaultPlayerProfile}
+
+	entries, err := os.ReadDir(filepath.Join(root, playerProfilesDir))
+	if err != nil {
+		return players
+	}
+
+	var others []string
+	for _, entry := range entries {
+		if entry.IsDir() && ValidPlayerName(entry.Name()) && entry.Name() != DefaultPlayerProfile {
+			others = append(others, entry.Name())
+		}
+	}
+	sort.Strings(others)
+	return append(players, others...)
+}
+
+// loadActivePlayer returns the player profile used last, the default if there is none
+func loadActivePlayer(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, playerFilename))
+	if err != nil {
+		return DefaultPlayerProfile
+	}
+
+	var active struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &active); err != nil || !ValidPlayerName(active.Name) {
+		return DefaultPlayerProfile
+	}
+	if _, err := os.Stat(playerDir(root, active.Name)); err != nil {
+		return DefaultPlayerProfile
+	}
+	return active.Name
+}
+
+// saveActivePlayer remembers the player profile to use on the next launch
+func saveActivePlayer(root, name string) error {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return fmt.Errorf("failed to marshal player: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, playerFilename), data, 0644); err != nil {
+		return fmt.Errorf("failed to write player: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package external
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidPlayerName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"alice", true},
+		{"Player_2", true},
+		{"left-hand", true},
+		{"", false},
+		{"../alice", false},
+		{"two words", false},
+		{"averyveryverylongname", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidPlayerName(tt.name); got != tt.valid {
+			t.Errorf("ValidPlayerName(%q) = %v, want %v", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestSwitchPlayerProfile(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(root)
+	m.SetServerOverride("http://127.0.0.1:1")
+	if err := m.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.getMonitor().Stop() })
+
+	m.GetSettings().AudioOffset = 40
+	if err := m.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	switched := 0
+	m.OnPlayerProfileChange(func() { switched++ })
+
+	// A new player starts from the defaults
+	if err := m.SwitchPlayerProfile("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if switched != 1 || m.GetPlayerProfile() != "bob" {
+		t.Fatalf("not switched to bob: %d %s", switched, m.GetPlayerProfile())
+	}
+	if m.GetSettings().AudioOffset != 0 {
+		t.Fatalf("bob has the default player's offset %d", m.GetSettings().AudioOffset)
+	}
+	m.GetSettings().AudioOffset = -25
+	if err := m.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.SwitchPlayerProfile(DefaultPlayerProfile); err != nil {
+		t.Fatal(err)
+	}
+	if m.GetSettings().AudioOffset != 40 {
+		t.Fatalf("default player's offset lost, got %d", m.GetSettings().AudioOffset)
+	}
+
+	if err := m.SwitchPlayerProfile("../escape"); err == nil {
+		t.Fatal("switched to an invalid profile")
+	}
+
+	want := []string{DefaultPlayerProfile, "bob"}
+	if got := m.GetPlayerProfiles(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected profiles %v, got %v", want, got)
+	}
+	if got := loadActivePlayer(root); got != DefaultPlayerProfile {
+		t.Fatalf("expected %s to be picked next launch, got %s", DefaultPlayerProfile, got)
+	}
+}
+
+func TestPlayerOverride(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(root)
+	m.SetServerOverride("http://127.0.0.1:1")
+	m.SetPlayerOverride("carol")
+	if err := m.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.getMonitor().Stop() })
+
+	if m.GetPlayerProfile() != "carol" {
+		t.Fatalf("expected carol, got %s", m.GetPlayerProfile())
+	}
+	if got := loadActivePlayer(root); got != DefaultPlayerProfile {
+		t.Fatalf("override changed the player for the next launch to %s", got)
+	}
+}
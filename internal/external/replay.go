@@ -0,0 +1,61 @@
+package external
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+// Every play is kept as a replay file under the player's replays folder
+const (
+	replaysDir      = "replays"
+	ReplayExtension = ".str"
+)
+
+// SaveReplay writes a replay to the replays folder, returning the path of its file
+func (s *Storage) SaveReplay(replay *judge.Replay, playedAt time.Time) (string, error) {
+	dir := filepath.Join(s.basePath, replaysDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create replays directory: %w", err)
+	}
+
+	data, err := replay.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode replay: %w", err)
+	}
+
+	path := filepath.Join(dir, replayFilename(replay, playedAt))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write replay: %w", err)
+	}
+	return path, nil
+}
+
+// LoadReplay reads a replay file, which may come from another player
+func LoadReplay(path string) (*judge.Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay: %w", err)
+	}
+
+	replay := &judge.Replay{}
+	if err := replay.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("failed to decode replay %s: %w", path, err)
+	}
+	return replay, nil
+}
+
+// replayFilename names a replay after its chart and when it was played, e.g. 1e35451d819b-5-20240102-150405.str
+func replayFilename(replay *judge.Replay, playedAt time.Time) string {
+	song := replay.Song
+	if len(song) > 12 {
+		song = song[:12]
+	}
+	if song == "" {
+		song = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s%s", song, replay.Difficulty, playedAt.Format("20060102-150405"), ReplayExtension)
+}
@@ -0,0 +1,83 @@
+package external
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/liqmix/slaptrax/internal/logger"
+)
+
+// Secrets are bound to this machine and user, so a copied auth file is useless elsewhere.
+// This keeps tokens out of backups and synced folders, it doesn't stop someone
+// already running code as the user.
+var (
+	secretKeyOnce sync.Once
+	secretKey     []byte
+)
+
+func machineKey() []byte {
+	secretKeyOnce.Do(func() {
+		id, err := machineID()
+		if err != nil {
+			logger.Warn("No machine id, binding secrets to the host name: %v", err)
+			id, _ = os.Hostname()
+		}
+		home, _ := os.UserHomeDir()
+
+		sum := sha256.Sum256([]byte("slaptrax credentials\x00" + id + "\x00" + home))
+		secretKey = sum[:]
+	})
+	return secretKey
+}
+
+// sealSecret encrypts a secret with the machine key
+func sealSecret(plaintext string) (string, error) {
+	gcm, err := newSecretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret decrypts a secret sealed on this machine
+func openSecret(sealed string) (string, error) {
+	gcm, err := newSecretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("malformed secret: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("malformed secret")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("secret was sealed elsewhere: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func newSecretCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(machineKey())
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,90 @@
+package external
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/liqmix/slaptrax/internal/config"
+)
+
+// The server can be picked for a single run, taking precedence over the
+// profile chosen in settings:
+//
+//	-server flag     see SetServerOverride
+//	SLAPTRAX_SERVER  environment variable
+//
+// Either may be a profile name or a server URL.
+const serverEnv = "SLAPTRAX_SERVER"
+
+// DefaultServerProfile is the official server
+var DefaultServerProfile = ServerProfile{
+	Name:     config.TITLE,
+	Endpoint: config.SERVER_ENDPOINT,
+}
+
+// GetServerProfiles lists the official server followed by the configured ones
+func (s *Settings) GetServerProfiles() []ServerProfile {
+	profiles := []ServerProfile{DefaultServerProfile}
+	for _, p := range s.ServerProfiles {
+		if p.Name == "" || p.Endpoint == "" || p.Name == DefaultServerProfile.Name {
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// GetServerProfile finds a profile by name
+func (s *Settings) GetServerProfile(name string) (ServerProfile, bool) {
+	for _, p := range s.GetServerProfiles() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return ServerProfile{}, false
+}
+
+// resolveServerProfile picks the profile to use from the override or the settings
+func resolveServerProfile(settings *Settings, override string) ServerProfile {
+	if override == "" {
+		override = os.Getenv(serverEnv)
+	}
+
+	if override != "" {
+		if p, ok := settings.GetServerProfile(override); ok {
+			return p
+		}
+		if u, err := url.Parse(override); err == nil && u.Host != "" {
+			// Named after the host so its credentials are kept between runs
+			return ServerProfile{Name: u.Host, Endpoint: strings.TrimSuffix(override, "/")}
+		}
+	}
+
+	if p, ok := settings.GetServerProfile(settings.ServerProfile); ok {
+		return p
+	}
+	return DefaultServerProfile
+}
+
+// profileFilename returns the file name used for per-server data of a profile.
+// The official server keeps the plain name so existing files still apply.
+func profileFilename(filename, profile string) string {
+	if profile == "" || profile == DefaultServerProfile.Name {
+		return filename
+	}
+
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, profile)
+
+	ext := ""
+	if i := strings.LastIndex(filename, "."); i >= 0 {
+		filename, ext = filename[:i], filename[i:]
+	}
+	return filename + "." + slug + ext
+}
@@ -1,5 +1,111 @@
 package external
 
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+// Settings that belong to the machine they were set on and never leave it
+var localSettings = map[string]bool{
+	"version":            true,
+	"last_modified":      true,
+	"last_sync":          true,
+	"is_new_user":        true,
+	"field_modified":     true,
+	"fullscreen":         true,
+	"screen_width":       true,
+	"screen_height":      true,
+	"render_width":       true,
+	"render_height":      true,
+	"fixed_render_scale": true,
+	"edge_play_area":     true,
+	"server_profile":     true,
+	"server_profiles":    true,
+}
+
+// Field index of every synced setting by its json name
+var syncedSettings = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(Settings{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || localSettings[name] {
+			continue
+		}
+		fields[name] = i
+	}
+	return fields
+}()
+
+// MarkChanged stamps every synced setting that differs from prev as changed at now
+func (s *Settings) MarkChanged(prev *Settings, now time.Time) {
+	if prev == nil {
+		return
+	}
+
+	current := reflect.ValueOf(s).Elem()
+	previous := reflect.ValueOf(prev).Elem()
+	for name, i := range syncedSettings {
+		if reflect.DeepEqual(current.Field(i).Interface(), previous.Field(i).Interface()) {
+			continue
+		}
+		if s.FieldModified == nil {
+			s.FieldModified = make(map[string]time.Time)
+		}
+		s.FieldModified[name] = now
+	}
+}
+
+// SyncedValues returns the synced settings that were changed on this machine.
+// Untouched defaults are left out so they never override another machine.
+func (s *Settings) SyncedValues() map[string]SettingValue {
+	values := make(map[string]SettingValue)
+	v := reflect.ValueOf(s).Elem()
+	for name, modified := range s.FieldModified {
+		i, ok := syncedSettings[name]
+		if !ok {
+			continue
+		}
+
+		data, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			continue
+		}
+		values[name] = SettingValue{Value: data, Modified: modified}
+	}
+	return values
+}
+
+// ApplySynced takes every remote setting that was changed more recently
+// than the local one, reporting if any were taken
+func (s *Settings) ApplySynced(remote map[string]SettingValue) bool {
+	changed := false
+	v := reflect.ValueOf(s).Elem()
+	for name, setting := range remote {
+		i, ok := syncedSettings[name]
+		if !ok || !setting.Modified.After(s.FieldModified[name]) {
+			continue
+		}
+
+		value := reflect.New(v.Field(i).Type())
+		if err := json.Unmarshal(setting.Value, value.Interface()); err != nil {
+			continue
+		}
+		v.Field(i).Set(value.Elem())
+
+		if s.FieldModified == nil {
+			s.FieldModified = make(map[string]time.Time)
+		}
+		s.FieldModified[name] = setting.Modified
+		changed = true
+	}
+	return changed
+}
+
 // MergeFrom merges settings from another settings object
 func (s *Settings) MergeFrom(other *Settings) {
 	if other == nil {
@@ -72,6 +178,31 @@ func (s *Settings) MergeFrom(other *Settings) {
 	if other.CornerNoteColor != "" {
 		s.CornerNoteColor = other.CornerNoteColor
 	}
+
+	s.Modifiers = other.Modifiers
+
+	if other.Judgement != "" {
+		s.Judgement = other.Judgement
+	}
+	if other.CustomJudgement != nil {
+		custom := *other.CustomJudgement
+		s.CustomJudgement = &custom
+	}
+}
+
+// JudgementProfile returns the profile plays are judged with.
+// A missing or invalid custom table falls back to Standard.
+func (s *Settings) JudgementProfile() judge.Profile {
+	if s.Judgement != judge.CustomProfile {
+		return judge.GetProfile(s.Judgement)
+	}
+	if s.CustomJudgement == nil || s.CustomJudgement.Validate() != nil {
+		return judge.Standard
+	}
+
+	custom := *s.CustomJudgement
+	custom.Name = judge.CustomProfile
+	return custom
 }
 
 // Clone creates a deep copy of settings
@@ -81,6 +212,17 @@ func (s *Settings) Clone() *Settings {
 	}
 
 	clone := *s // Shallow copy
+	clone.ServerProfiles = append([]ServerProfile(nil), s.ServerProfiles...)
+	if s.CustomJudgement != nil {
+		custom := *s.CustomJudgement
+		clone.CustomJudgement = &custom
+	}
+	if s.FieldModified != nil {
+		clone.FieldModified = make(map[string]time.Time, len(s.FieldModified))
+		for name, modified := range s.FieldModified {
+			clone.FieldModified[name] = modified
+		}
+	}
 
 	return &clone
 }
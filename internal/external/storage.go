@@ -3,27 +3,57 @@ package external
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/liqmix/slaptrax/internal/logger"
 )
 
 const (
 	settingsFilename = "settings.json"
 	authFilename     = "auth.json"
+	outboxFilename   = "outbox.json"
+)
+
+const (
+	// Folder under the user config directory holding all user data
+	dataDirName = "slaptrax"
+	// Older builds kept user data relative to the working directory
+	legacyStoragePath = "storage"
 )
 
+// DataDir returns where user data is stored, under the platform user config directory:
+//
+//	Windows  %AppData%\slaptrax
+//	macOS    ~/Library/Application Support/slaptrax
+//	Linux    $XDG_CONFIG_HOME/slaptrax or ~/.config/slaptrax
+func DataDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		logger.Warn("No user config directory, storing data in %s: %v", legacyStoragePath, err)
+		return legacyStoragePath
+	}
+	return filepath.Join(dir, dataDirName)
+}
+
 // Storage handles persistent storage and server communication
 type Storage struct {
 	basePath string
+	profile  string // Server profile, credentials and queued plays are kept per server
 	client   *APIClient
+	history  *History
 }
 
-// StoredCredentials represents saved login information
+// StoredCredentials represents saved login information.
+// The refresh token is only written encrypted, see sealSecret.
 type StoredCredentials struct {
-	Username     string    `json:"username"`
-	RefreshToken string    `json:"refresh_token"`
-	SavedAt      time.Time `json:"saved_at"`
+	Username              string    `json:"username"`
+	RefreshToken          string    `json:"refresh_token,omitempty"` // Plaintext from older builds
+	EncryptedRefreshToken string    `json:"encrypted_refresh_token,omitempty"`
+	SavedAt               time.Time `json:"saved_at"`
 }
 
 // NewStorage creates a new storage instance
@@ -31,9 +61,21 @@ func NewStorage(basePath string) *Storage {
 	return &Storage{
 		basePath: basePath,
 		client:   NewAPIClient(),
+		history:  NewHistory(filepath.Join(basePath, historyFilename)),
 	}
 }
 
+// SetBasePath moves storage to another folder, for another player
+func (s *Storage) SetBasePath(basePath string) {
+	s.basePath = basePath
+	s.history = NewHistory(filepath.Join(basePath, historyFilename))
+}
+
+// SetProfile switches the server profile credentials and queued plays are stored for
+func (s *Storage) SetProfile(profile string) {
+	s.profile = profile
+}
+
 // SaveSettings persists settings to disk
 func (s *Storage) SaveSettings(settings *Settings) error {
 	path := filepath.Join(s.basePath, settingsFilename)
@@ -55,7 +97,8 @@ func (s *Storage) SaveSettings(settings *Settings) error {
 	return nil
 }
 
-// LoadSettings reads settings from disk
+// LoadSettings reads settings from disk, upgrading them from older builds.
+// Missing settings take their defaults and anything out of range is put back in it.
 func (s *Storage) LoadSettings() (*Settings, error) {
 	path := filepath.Join(s.basePath, settingsFilename)
 	data, err := os.ReadFile(path)
@@ -63,35 +106,76 @@ func (s *Storage) LoadSettings() (*Settings, error) {
 		return nil, fmt.Errorf("failed to read settings: %w", err)
 	}
 
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal settings: %w", err)
+	}
+
+	version, err := migrateSettings(raw)
+	if err != nil {
+		return nil, err
+	}
+	migrated := version < SettingsVersion
+	if migrated {
+		// Keep what was there in case a migration got it wrong
+		backup := fmt.Sprintf("%s.v%d.bak", path, version)
+		if err := os.WriteFile(backup, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to back up settings: %w", err)
+		}
+		if data, err = json.Marshal(raw); err != nil {
+			return nil, fmt.Errorf("failed to marshal settings: %w", err)
+		}
+	}
+
+	settings := GetDefaultSettings()
+	if err := json.Unmarshal(data, settings); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal settings: %w", err)
 	}
+	settings.Validate()
 
-	return &settings, nil
+	if migrated {
+		logger.Info("Migrated settings from version %d to %d", version, SettingsVersion)
+		if err := s.SaveSettings(settings); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
 }
 
-// SaveCredentials stores login credentials
+// SaveCredentials stores login credentials, readable only by the user
 func (s *Storage) SaveCredentials(username, refreshToken string) error {
-	path := filepath.Join(s.basePath, authFilename)
+	path := filepath.Join(s.basePath, profileFilename(authFilename, s.profile))
 
 	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
 		return fmt.Errorf("failed to create auth directory: %w", err)
 	}
 
-	creds := StoredCredentials{
-		Username:     username,
-		RefreshToken: refreshToken,
-		SavedAt:      time.Now(),
+	sealed, err := sealSecret(refreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %w", err)
 	}
 
+	return writeCredentials(path, StoredCredentials{
+		Username:              username,
+		EncryptedRefreshToken: sealed,
+		SavedAt:               time.Now(),
+	})
+}
+
+// writeCredentials writes an auth file readable only by the user
+func writeCredentials(path string, creds StoredCredentials) error {
 	data, err := json.MarshalIndent(creds, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal credentials: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	// Written through a new file, as WriteFile keeps the permissions of an existing one
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
 		return fmt.Errorf("failed to write credentials: %w", err)
 	}
 
@@ -100,7 +184,7 @@ func (s *Storage) SaveCredentials(username, refreshToken string) error {
 
 // LoadCredentials reads stored credentials
 func (s *Storage) LoadCredentials() (*StoredCredentials, error) {
-	path := filepath.Join(s.basePath, authFilename)
+	path := filepath.Join(s.basePath, profileFilename(authFilename, s.profile))
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
 		return nil, nil
@@ -114,14 +198,184 @@ func (s *Storage) LoadCredentials() (*StoredCredentials, error) {
 		return nil, fmt.Errorf("failed to unmarshal credentials: %w", err)
 	}
 
+	if creds.EncryptedRefreshToken == "" {
+		// Encrypt credentials saved by older builds
+		if creds.RefreshToken != "" {
+			if err := s.SaveCredentials(creds.Username, creds.RefreshToken); err != nil {
+				logger.Error("Failed to encrypt stored credentials: %v", err)
+			}
+		}
+		return &creds, nil
+	}
+
+	creds.RefreshToken, err = openSecret(creds.EncryptedRefreshToken)
+	if err != nil {
+		// Copied from another machine, log in again
+		logger.Warn("Ignoring stored credentials: %v", err)
+		return nil, nil
+	}
 	return &creds, nil
 }
 
 // ClearCredentials removes stored credentials
 func (s *Storage) ClearCredentials() error {
-	path := filepath.Join(s.basePath, authFilename)
+	path := filepath.Join(s.basePath, profileFilename(authFilename, s.profile))
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil
 	}
 	return os.Remove(path)
 }
+
+// SaveOutbox persists the plays waiting to be submitted
+func (s *Storage) SaveOutbox(scores []Score) error {
+	path := filepath.Join(s.basePath, profileFilename(outboxFilename, s.profile))
+
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	data, err := json.Marshal(scores)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox: %w", err)
+	}
+
+	// Write through a temporary file so a crash can't lose queued plays
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+
+	return nil
+}
+
+// LoadOutbox reads the plays waiting to be submitted
+func (s *Storage) LoadOutbox() ([]Score, error) {
+	path := filepath.Join(s.basePath, profileFilename(outboxFilename, s.profile))
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	var scores []Score
+	if err := json.Unmarshal(data, &scores); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outbox: %w", err)
+	}
+
+	return scores, nil
+}
+
+// migrateStorage moves user data left in the folder used by older builds,
+// leaving alone anything already in the new one
+func migrateStorage(from, to string) error {
+	if from == to {
+		return nil
+	}
+
+	entries, err := os.ReadDir(from)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", from, err)
+	}
+
+	if err := os.MkdirAll(to, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", to, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		src, dst := filepath.Join(from, entry.Name()), filepath.Join(to, entry.Name())
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := moveFile(src, dst); err != nil {
+			return fmt.Errorf("failed to move %s: %w", entry.Name(), err)
+		}
+		logger.Info("Moved %s to %s", src, dst)
+
+		if isAuthFile(entry.Name()) {
+			if err := sealCredentials(dst); err != nil {
+				return fmt.Errorf("failed to secure %s: %w", entry.Name(), err)
+			}
+		}
+	}
+
+	// Only goes once everything was moved
+	os.Remove(from)
+	return nil
+}
+
+// isAuthFile reports whether a file holds the credentials of a server profile
+func isAuthFile(name string) bool {
+	ext := filepath.Ext(authFilename)
+	base := strings.TrimSuffix(authFilename, ext)
+	return name == authFilename || (strings.HasPrefix(name, base+".") && strings.HasSuffix(name, ext))
+}
+
+// sealCredentials rewrites moved credentials encrypted and readable only by the
+// user, as older builds stored them in plaintext with default permissions
+func sealCredentials(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var creds StoredCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return fmt.Errorf("failed to unmarshal credentials: %w", err)
+	}
+
+	if creds.EncryptedRefreshToken == "" && creds.RefreshToken != "" {
+		sealed, err := sealSecret(creds.RefreshToken)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt credentials: %w", err)
+		}
+		creds.RefreshToken, creds.EncryptedRefreshToken = "", sealed
+	}
+	return writeCredentials(path, creds)
+}
+
+// moveFile renames a file, copying it when that isn't possible across drives
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
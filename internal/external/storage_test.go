@@ -0,0 +1,168 @@
+package external
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+func TestCredentialsEncrypted(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	if err := s.SaveCredentials("slapper", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(s.basePath, authFilename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Fatal("refresh token stored in plaintext")
+	}
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("auth file readable by others: %v", info.Mode().Perm())
+	}
+
+	creds, err := s.LoadCredentials()
+	if err != nil || creds == nil {
+		t.Fatalf("failed to load credentials: %v", err)
+	}
+	if creds.Username != "slapper" || creds.RefreshToken != "secret-token" {
+		t.Fatalf("unexpected credentials %+v", creds)
+	}
+}
+
+func TestCredentialsUpgraded(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	path := filepath.Join(s.basePath, authFilename)
+	legacy := `{"username": "slapper", "refresh_token": "secret-token"}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := s.LoadCredentials()
+	if err != nil || creds == nil || creds.RefreshToken != "secret-token" {
+		t.Fatalf("failed to load plaintext credentials: %+v %v", creds, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret-token") {
+		t.Fatal("plaintext credentials were not encrypted")
+	}
+}
+
+func TestCredentialsFromElsewhere(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	path := filepath.Join(s.basePath, authFilename)
+	copied := `{"username": "slapper", "encrypted_refresh_token": "c2VhbGVkIG9uIGFub3RoZXIgbWFjaGluZQ=="}`
+	if err := os.WriteFile(path, []byte(copied), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := s.LoadCredentials()
+	if err != nil || creds != nil {
+		t.Fatalf("expected no credentials, got %+v %v", creds, err)
+	}
+}
+
+func TestMigrateStorage(t *testing.T) {
+	from := filepath.Join(t.TempDir(), "storage")
+	to := filepath.Join(t.TempDir(), "slaptrax")
+	os.MkdirAll(from, 0755)
+	os.MkdirAll(to, 0755)
+	os.WriteFile(filepath.Join(from, settingsFilename), []byte("old"), 0644)
+	os.WriteFile(filepath.Join(from, historyFilename), []byte("plays"), 0644)
+	os.WriteFile(filepath.Join(to, settingsFilename), []byte("new"), 0644)
+
+	if err := migrateStorage(from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(to, historyFilename)); string(data) != "plays" {
+		t.Fatalf("history not moved, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(to, settingsFilename)); string(data) != "new" {
+		t.Fatalf("newer settings overwritten, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(from, historyFilename)); !os.IsNotExist(err) {
+		t.Fatal("moved file left behind")
+	}
+
+	// Nothing to do the next time
+	if err := migrateStorage(filepath.Join(t.TempDir(), "missing"), to); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateStorageSealsCredentials(t *testing.T) {
+	from := filepath.Join(t.TempDir(), "storage")
+	to := filepath.Join(t.TempDir(), "slaptrax")
+	os.MkdirAll(from, 0755)
+	legacy := `{"username": "slapper", "refresh_token": "secret-token"}`
+	names := []string{authFilename, profileFilename(authFilename, "local")}
+	for _, name := range names {
+		os.WriteFile(filepath.Join(from, name), []byte(legacy), 0644)
+	}
+
+	if err := migrateStorage(from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		path := filepath.Join(to, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret-token") {
+			t.Fatalf("%s moved in plaintext", name)
+		}
+		if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Fatalf("%s readable by others: %v", name, info.Mode().Perm())
+		}
+	}
+
+	creds, err := NewStorage(to).LoadCredentials()
+	if err != nil || creds == nil || creds.RefreshToken != "secret-token" {
+		t.Fatalf("failed to load moved credentials: %+v %v", creds, err)
+	}
+}
+
+func TestReplaySaved(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	replay := judge.NewReplay(judge.Options{InputOffset: 20, TravelTime: 5000})
+	replay.Song = "1e35451d819b234a457b53e331fb5844afb7510073bd760b90b9042700fbcea4"
+	replay.Difficulty = 5
+	replay.Record(1000, "left_top", true, true)
+	replay.Record(1100, "left_top", false, false)
+
+	path, err := s.SaveReplay(replay, time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(s.basePath, replaysDir, "1e35451d819b-5-20240102-150405.str"); path != expected {
+		t.Errorf("expected %s, got %s", expected, path)
+	}
+
+	loaded, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Song != replay.Song || loaded.Options != replay.Options || len(loaded.Events) != 2 {
+		t.Errorf("expected %+v, got %+v", replay, loaded)
+	}
+
+	if err := os.WriteFile(path, []byte("not a replay"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadReplay(path); !errors.Is(err, judge.ErrInvalidReplay) {
+		t.Errorf("expected %v, got %v", judge.ErrInvalidReplay, err)
+	}
+}
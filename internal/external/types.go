@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/liqmix/slaptrax/internal/judge"
 )
 
 // LoginState represents the current authentication state
@@ -15,6 +17,7 @@ const (
 	StateOffline
 	StateOnline
 	StateLoggingIn
+	StateReconnecting // Logged in but the server can't be reached
 )
 
 // Settings represents user preferences and configuration
@@ -24,6 +27,9 @@ type Settings struct {
 	LastSync     time.Time `json:"last_sync"`
 	IsNewUser    bool      `json:"is_new_user"`
 
+	// When each synced setting was last changed on this machine, see SyncedValues
+	FieldModified map[string]time.Time `json:"field_modified,omitempty"`
+
 	// Game Settings
 	Locale           string `json:"locale"`
 	Fullscreen       bool   `json:"fullscreen"`
@@ -50,8 +56,25 @@ type Settings struct {
 	DisableHoldNotes   bool    `json:"disable_hold_notes"`
 	DisableHitEffects  bool    `json:"disable_hit_effects"`
 	DisableLaneEffects bool    `json:"disable_lane_effects"`
-	EdgePlayArea       bool    `json:"fullscreen_play_area"`
+	EdgePlayArea       bool    `json:"edge_play_area"`
 	Use3DNotes         bool    `json:"use_3d_notes"`
+
+	// Modifiers played with, see judge.Modifiers
+	Modifiers judge.Mods `json:"modifiers"`
+
+	// Judgement Settings, see JudgementProfile
+	Judgement       string         `json:"judgement"`
+	CustomJudgement *judge.Profile `json:"custom_judgement,omitempty"` // Windows and values of judge.CustomProfile
+
+	// Server Settings
+	ServerProfile  string          `json:"server_profile"`
+	ServerProfiles []ServerProfile `json:"server_profiles,omitempty"`
+}
+
+// ServerProfile is a named server the game can play against
+type ServerProfile struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"` // e.g. "https://liq.mx/slapi/v1"
 }
 
 func (s *Settings) Value() (driver.Value, error) {
@@ -72,6 +95,12 @@ func (s *Settings) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, s)
 }
 
+// SettingValue is a single synced setting with the time it was last changed
+type SettingValue struct {
+	Value    json.RawMessage `json:"value"`
+	Modified time.Time       `json:"modified"`
+}
+
 // User represents the current user state
 type User struct {
 	Username string    `json:"username"`
@@ -107,4 +136,34 @@ type Score struct {
 	MaxCombo   int       `json:"max_combo"`
 	PlayedAt   time.Time `json:"played_at"`
 	Difficulty int       `json:"difficulty"`
+	Verified   bool      `json:"verified"`
+	PP         float64   `json:"pp"`
+
+	// ID of the judge.Profile the play was judged with, see judge.JudgementID
+	Judgement string `json:"judgement,omitempty"`
+
+	// Modifiers the play was started with, the score already has their multiplier applied
+	Mods     judge.Mods `json:"mods,omitempty"`
+	Unranked bool       `json:"unranked,omitempty"` // Kept off leaderboards and ratings, see judge.Mods.Unranked
+
+	// Generated for each play so the server stores it only once however often it's sent
+	PlayID string `json:"play_id,omitempty"`
+
+	// Why the server refused the play, it stays in the outbox to be shown locally
+	// and isn't sent again
+	Rejected string `json:"rejected,omitempty"`
+
+	// Input stream of the play, only sent on submission
+	Replay *judge.Replay `json:"replay,omitempty"`
+
+	// Place on the chart leaderboard, only set on leaderboard entries
+	Position int `json:"position,omitempty"`
+}
+
+// Leaderboard is a page of a chart leaderboard
+type Leaderboard struct {
+	Total    int     `json:"total"`
+	Offset   int     `json:"offset"`
+	Position int     `json:"position,omitempty"`
+	Entries  []Score `json:"entries"`
 }
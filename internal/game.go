@@ -11,6 +11,7 @@ import (
 	"github.com/liqmix/slaptrax/internal/cache"
 	"github.com/liqmix/slaptrax/internal/debug"
 	"github.com/liqmix/slaptrax/internal/display"
+	"github.com/liqmix/slaptrax/internal/external"
 	"github.com/liqmix/slaptrax/internal/input"
 	"github.com/liqmix/slaptrax/internal/logger"
 	"github.com/liqmix/slaptrax/internal/state"
@@ -25,7 +26,48 @@ const (
 	startingTicks     = 200
 )
 
+// Replay file to watch instead of starting at the title, see SetStartingReplay
+var startingReplay string
+
+// SetStartingReplay starts the game watching the replay file at path
+func SetStartingReplay(path string) {
+	startingReplay = path
+}
+
+// getReplayState returns the state watching the starting replay, nil if there isn't one to watch
+func getReplayState() *RenderState {
+	if startingReplay == "" {
+		return nil
+	}
+
+	replay, err := external.LoadReplay(startingReplay)
+	if err != nil {
+		logger.Warn("Failed to load replay: %v", err)
+		return nil
+	}
+	song := assets.GetLoadedSong(replay.Song)
+	if song == nil {
+		logger.Warn("Replay is of a song that isn't installed: %s", replay.Song)
+		return nil
+	}
+	difficulty := types.Difficulty(replay.Difficulty)
+	if _, ok := song.Charts[difficulty]; !ok {
+		logger.Warn("Replay is of a chart that isn't installed: %s %d", song.Title, replay.Difficulty)
+		return nil
+	}
+
+	return GetState(types.GameStatePlay, &state.PlayArgs{
+		Song:       song,
+		Difficulty: difficulty,
+		Replay:     replay,
+	})
+}
+
 func getStartingState() *RenderState {
+	if s := getReplayState(); s != nil {
+		return s
+	}
+
 	// startingState := types.GameStatePlay
 	// song := types.GetAllSongs()[0]
 	// diff := song.GetDifficulties()[0]
@@ -141,6 +183,7 @@ func (g *Game) Update() error {
 
 	audio.Update()
 	input.Update()
+	external.Update()
 
 	if input.JustActioned(input.ActionToggleDebug) {
 		g.debugster.Toggle()
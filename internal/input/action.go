@@ -15,6 +15,7 @@ const (
 	ActionLeft
 	ActionRight
 	ActionToggleDebug
+	ActionToggleAutoplay
 
 	// Track activations
 	ActionLeftBottom
@@ -35,6 +36,8 @@ var actionToKey = map[Action][]ebiten.Key{
 	ActionLeft:        {ebiten.KeyArrowLeft},
 	ActionRight:       {ebiten.KeyArrowRight},
 	ActionToggleDebug: {ebiten.KeyF2},
+
+	ActionToggleAutoplay: {ebiten.KeyF3},
 }
 
 func (a Action) String() string {
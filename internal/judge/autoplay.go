@@ -0,0 +1,96 @@
+package judge
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// How long autoplay holds a tap for, in ms
+const autoplayTapLength = 30
+
+// Humanize makes autoplay play less than perfectly
+type Humanize struct {
+	Jitter   int64   // Most ms a press or release lands off its target, either way
+	MissRate float64 // Fraction of notes not pressed at all, 0 to 1
+	Seed     int64   // Same seed, same play
+}
+
+type autoplayPress struct {
+	time    int64
+	release int64 // 0 for taps
+}
+
+// Autoplay plays notes keyed by the schema track name they're played on, see Options.Arrange,
+// returning the replay of the play. Without humanizing every note is hit on its target
+// and holds are held to their release, so every chart that can be played perfectly
+// scores MaxScore before modifier multipliers.
+func Autoplay(notes map[string][]*Note, opts Options, h Humanize) *Replay {
+	rng := rand.New(rand.NewSource(h.Seed))
+	jitter := func() int64 {
+		if h.Jitter <= 0 {
+			return 0
+		}
+		return rng.Int63n(2*h.Jitter+1) - h.Jitter
+	}
+
+	replay := NewReplay(opts)
+	for _, track := range trackOrder {
+		trackNotes := make([]*Note, len(notes[track]))
+		copy(trackNotes, notes[track])
+		sort.SliceStable(trackNotes, func(i, j int) bool {
+			return trackNotes[i].Target < trackNotes[j].Target
+		})
+
+		// Hits are judged with the input offset added, so autoplay presses that much later
+		presses := make([]autoplayPress, 0, len(trackNotes))
+		var last *Note
+		for _, n := range trackNotes {
+			if h.MissRate > 0 && rng.Float64() < h.MissRate {
+				continue
+			}
+			// A press only hits one note, notes sharing a target get the one press
+			if last != nil && last.Target == n.Target {
+				continue
+			}
+			last = n
+
+			p := autoplayPress{time: n.Target + opts.InputOffset + jitter()}
+			if n.TargetRelease > 0 && !opts.Mods.Has(ModAllTaps) {
+				p.release = max(n.TargetRelease+opts.InputOffset+jitter(), p.time+1)
+			}
+			presses = append(presses, p)
+		}
+		sort.SliceStable(presses, func(i, j int) bool {
+			return presses[i].time < presses[j].time
+		})
+
+		for i, p := range presses {
+			replay.Events = append(replay.Events, InputEvent{Time: p.time, Track: track, Pressed: true})
+
+			release := p.time + autoplayTapLength
+			if p.release > 0 {
+				release = p.release
+			}
+			if i+1 < len(presses) && presses[i+1].time <= release {
+				next := &presses[i+1]
+				switch {
+				case p.release == 0:
+					// Taps let go in time for the next note
+					release = max(next.time-1, p.time+1)
+				case next.time == release:
+					// Holds are held to their last tick, the next note is pressed right after
+					next.time++
+				default:
+					// Overlapping notes, the hold runs straight into the next one
+					continue
+				}
+			}
+			replay.Events = append(replay.Events, InputEvent{Time: release, Track: track, Pressed: false})
+		}
+	}
+
+	sort.SliceStable(replay.Events, func(i, j int) bool {
+		return replay.Events[i].Time < replay.Events[j].Time
+	})
+	return replay
+}
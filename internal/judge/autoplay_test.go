@@ -0,0 +1,85 @@
+package judge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+func autoplayChart() *schema.ChartDataV2 {
+	return &schema.ChartDataV2{Tracks: map[string][]schema.NoteData{
+		schema.TrackLeftBottom: {
+			{Time: 1000, Type: schema.NoteTypeTap},
+			{Time: 1020, Type: schema.NoteTypeTap}, // Closer than a tap is held
+			{Time: 1500, Type: schema.NoteTypeHold, Duration: 500},
+			{Time: 2000, Type: schema.NoteTypeTap}, // On the release of the hold
+		},
+		schema.TrackRightBottom: {
+			{Time: 1000, Type: schema.NoteTypeTap}, // Chord
+			{Time: 3000, Type: schema.NoteTypeHold, Duration: 1000},
+		},
+	}}
+}
+
+func TestAutoplayPerfect(t *testing.T) {
+	chart := autoplayChart()
+	notes, err := NewNotes(chart, NewTempoMap(120, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []Options{
+		{},
+		{InputOffset: 35},
+		{InputOffset: -35},
+		{Mods: ModAllTaps},
+		{Mods: ModMirror | ModFlip | ModHidden},
+		{Mods: ModShuffle, Seed: 3},
+	} {
+		score, err := Simulate(chart, 120, Autoplay(opts.Arrange(notes), opts, Humanize{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := opts.Mods.score(MaxScore)
+		if score.Total() != expected || score.Slap != score.TotalNotes {
+			t.Errorf("%+v: expected %d with all slaps, got %d with %d/%d slaps",
+				opts, expected, score.Total(), score.Slap, score.TotalNotes)
+		}
+	}
+}
+
+func TestAutoplayHumanized(t *testing.T) {
+	chart := autoplayChart()
+	notes, err := NewNotes(chart, NewTempoMap(120, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Always missing never presses
+	replay := Autoplay(notes, Options{}, Humanize{MissRate: 1})
+	if len(replay.Events) != 0 {
+		t.Errorf("expected no input, got %v", replay.Events)
+	}
+	score, err := Simulate(chart, 120, replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Slop != score.TotalNotes {
+		t.Errorf("expected %d slops, got %d", score.TotalNotes, score.Slop)
+	}
+
+	// Jitter within the slap window is off the target but still hits
+	h := Humanize{Jitter: 10, Seed: 7}
+	replay = Autoplay(notes, Options{}, h)
+	if !reflect.DeepEqual(replay.Events, Autoplay(notes, Options{}, h).Events) {
+		t.Error("expected the same play from the same seed")
+	}
+	score, err = Simulate(chart, 120, replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Slop > 0 || score.Early+score.Late == 0 {
+		t.Errorf("expected hits off the target, got %+v", score)
+	}
+}
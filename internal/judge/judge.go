@@ -0,0 +1,24 @@
+package judge
+
+// MaxScore is the score of a perfect play on any chart
+const MaxScore = 100000
+
+// Rating mirrors types.HitRating, order is critical here
+type Rating int
+
+const (
+	Slap Rating = iota
+	Slip
+	Slop
+	None
+)
+
+// IsEarly reports if a hit diff (target - hit time) counts as early
+func IsEarly(diff int64) bool {
+	return diff > int64(Slap)
+}
+
+// IsLate reports if a hit diff (target - hit time) counts as late
+func IsLate(diff int64) bool {
+	return diff < int64(-Slap)
+}
@@ -0,0 +1,167 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+// Mods are the gameplay modifiers a play is started with, as bit flags
+type Mods uint32
+
+const (
+	ModMirror  Mods = 1 << iota // Left and right tracks swapped
+	ModFlip                     // Top and bottom tracks swapped
+	ModShuffle                  // Tracks shuffled by Options.Seed
+	ModSudden                   // Notes only appear partway down the lane
+	ModHidden                   // Notes fade out before the judgement line
+	ModAllTaps                  // Holds are judged as taps
+)
+
+// Modifier is a gameplay modifier and what it does to the score of a play
+type Modifier struct {
+	Mod        Mods
+	Name       string
+	Multiplier float64 // Applied to the score, 1 leaves it as is
+	Unranked   bool    // Scores are kept but never ranked
+}
+
+// Modifiers are every modifier a play can be started with, in the order they're listed.
+//
+// Multipliers never go above 1, a score can't pass MaxScore and the service rejects any that do,
+// so only modifiers that make a chart easier cost points.
+// Mirror and flip move notes to lanes that are just as hard to play, the chart is unchanged.
+// Sudden and hidden only make notes harder to read, playing them is a challenge of its own
+// and not an advantage, so they're ranked as they are.
+// Shuffled tracks are a different chart every play, so scores can't be compared.
+var Modifiers = []Modifier{
+	{Mod: ModMirror, Name: "mirror", Multiplier: 1},
+	{Mod: ModFlip, Name: "flip", Multiplier: 1},
+	{Mod: ModShuffle, Name: "shuffle", Multiplier: 1, Unranked: true},
+	{Mod: ModSudden, Name: "sudden", Multiplier: 1},
+	{Mod: ModHidden, Name: "hidden", Multiplier: 1},
+	{Mod: ModAllTaps, Name: "all_taps", Multiplier: 0.9},
+}
+
+var ErrInvalidMods = errors.New("invalid modifiers")
+
+// Track each track is moved to by ModMirror and ModFlip
+var (
+	mirrorTracks = map[string]string{
+		schema.TrackLeftBottom:   schema.TrackRightBottom,
+		schema.TrackLeftTop:      schema.TrackRightTop,
+		schema.TrackRightBottom:  schema.TrackLeftBottom,
+		schema.TrackRightTop:     schema.TrackLeftTop,
+		schema.TrackCenterBottom: schema.TrackCenterBottom,
+		schema.TrackCenterTop:    schema.TrackCenterTop,
+	}
+	flipTracks = map[string]string{
+		schema.TrackLeftBottom:   schema.TrackLeftTop,
+		schema.TrackLeftTop:      schema.TrackLeftBottom,
+		schema.TrackRightBottom:  schema.TrackRightTop,
+		schema.TrackRightTop:     schema.TrackRightBottom,
+		schema.TrackCenterBottom: schema.TrackCenterTop,
+		schema.TrackCenterTop:    schema.TrackCenterBottom,
+	}
+)
+
+func (m Mods) Has(mod Mods) bool {
+	return m&mod != 0
+}
+
+// Multiplier returns the product of the score multipliers of the modifiers
+func (m Mods) Multiplier() float64 {
+	multiplier := 1.0
+	for _, mod := range Modifiers {
+		if m.Has(mod.Mod) {
+			multiplier *= mod.Multiplier
+		}
+	}
+	return multiplier
+}
+
+// Unranked reports if any of the modifiers keeps a score from being ranked
+func (m Mods) Unranked() bool {
+	for _, mod := range Modifiers {
+		if m.Has(mod.Mod) && mod.Unranked {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that every modifier is known
+func (m Mods) Validate() error {
+	known := Mods(0)
+	for _, mod := range Modifiers {
+		known |= mod.Mod
+	}
+	if m&^known != 0 {
+		return fmt.Errorf("%w: unknown modifiers %#x", ErrInvalidMods, uint32(m&^known))
+	}
+	return nil
+}
+
+// String lists the names of the modifiers, e.g. "mirror+hidden"
+func (m Mods) String() string {
+	names := make([]string, 0, len(Modifiers))
+	for _, mod := range Modifiers {
+		if m.Has(mod.Mod) {
+			names = append(names, mod.Name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// score applies the multiplier of the modifiers to a score out of MaxScore
+func (m Mods) score(points int) int {
+	return int(math.Round(float64(points) * m.Multiplier()))
+}
+
+// Lanes returns the track each charted track is played on, keyed by schema track name.
+// Tracks are shuffled first, then mirrored and flipped.
+func (o Options) Lanes() map[string]string {
+	mods := o.Mods
+
+	order := make([]string, len(trackOrder))
+	copy(order, trackOrder)
+	if mods.Has(ModShuffle) {
+		// Shuffled by hand, the seeded sequence of rand.Source never changes
+		rng := rand.New(rand.NewSource(o.Seed))
+		for i := len(order) - 1; i > 0; i-- {
+			j := rng.Int63n(int64(i + 1))
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	lanes := make(map[string]string, len(trackOrder))
+	for i, track := range trackOrder {
+		lane := order[i]
+		if mods.Has(ModMirror) {
+			lane = mirrorTracks[lane]
+		}
+		if mods.Has(ModFlip) {
+			lane = flipTracks[lane]
+		}
+		lanes[track] = lane
+	}
+	return lanes
+}
+
+// Arrange moves the notes of a chart, keyed by schema track name, onto the tracks they're played on
+func (o Options) Arrange(notes map[string][]*Note) map[string][]*Note {
+	lanes := o.Lanes()
+	arranged := make(map[string][]*Note, len(notes))
+	for track, trackNotes := range notes {
+		lane, ok := lanes[track]
+		if !ok {
+			lane = track
+		}
+		arranged[lane] = trackNotes
+	}
+	return arranged
+}
@@ -0,0 +1,89 @@
+package judge
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+func TestModsScore(t *testing.T) {
+	tests := []struct {
+		mods     Mods
+		total    int
+		unranked bool
+		name     string
+	}{
+		{0, MaxScore, false, ""},
+		{ModMirror | ModFlip | ModSudden | ModHidden, MaxScore, false, "mirror+flip+sudden+hidden"},
+		{ModAllTaps, MaxScore * 9 / 10, false, "all_taps"},
+		{ModShuffle | ModAllTaps, MaxScore * 9 / 10, true, "shuffle+all_taps"},
+	}
+	for _, tt := range tests {
+		if got := tt.mods.score(MaxScore); got != tt.total {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.total, got)
+		}
+		if got := tt.mods.Unranked(); got != tt.unranked {
+			t.Errorf("%s: expected unranked %v, got %v", tt.name, tt.unranked, got)
+		}
+		if got := tt.mods.String(); got != tt.name {
+			t.Errorf("expected %q, got %q", tt.name, got)
+		}
+	}
+
+	// Every modifier at once still scores at most MaxScore
+	all := Mods(0)
+	for _, mod := range Modifiers {
+		all |= mod.Mod
+		if mod.Multiplier <= 0 || mod.Multiplier > 1 {
+			t.Errorf("%s: expected a multiplier above 0 and at most 1, got %v", mod.Name, mod.Multiplier)
+		}
+	}
+	if got := all.score(MaxScore); got > MaxScore {
+		t.Errorf("expected at most %d with every modifier, got %d", MaxScore, got)
+	}
+
+	if err := (ModHidden | 1<<31).Validate(); !errors.Is(err, ErrInvalidMods) {
+		t.Errorf("expected %v for an unknown modifier, got %v", ErrInvalidMods, err)
+	}
+}
+
+func TestLanes(t *testing.T) {
+	const (
+		lb, lt = schema.TrackLeftBottom, schema.TrackLeftTop
+		rb, rt = schema.TrackRightBottom, schema.TrackRightTop
+		cb, ct = schema.TrackCenterBottom, schema.TrackCenterTop
+	)
+
+	tests := []struct {
+		name     string
+		mods     Mods
+		expected map[string]string
+	}{
+		{"none", 0, map[string]string{lb: lb, lt: lt, rb: rb, rt: rt, cb: cb, ct: ct}},
+		{"mirror", ModMirror, map[string]string{lb: rb, lt: rt, rb: lb, rt: lt, cb: cb, ct: ct}},
+		{"flip", ModFlip, map[string]string{lb: lt, lt: lb, rb: rt, rt: rb, cb: ct, ct: cb}},
+		{"mirror and flip", ModMirror | ModFlip, map[string]string{lb: rt, lt: rb, rb: lt, rt: lb, cb: ct, ct: cb}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Options{Mods: tt.mods}).Lanes(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// Shuffling moves every track somewhere, the same way for the same seed
+	shuffled := Options{Mods: ModShuffle, Seed: 11}.Lanes()
+	if !reflect.DeepEqual(shuffled, Options{Mods: ModShuffle, Seed: 11}.Lanes()) {
+		t.Error("expected the same lanes from the same seed")
+	}
+	seen := make(map[string]bool)
+	for _, lane := range shuffled {
+		seen[lane] = true
+	}
+	if len(shuffled) != len(trackOrder) || len(seen) != len(trackOrder) {
+		t.Errorf("expected every track played once, got %v", shuffled)
+	}
+}
@@ -0,0 +1,47 @@
+package judge
+
+// Points keeps the score of a play as a fraction of MaxScore, so a perfect play
+// adds up to exactly MaxScore whatever the mix of notes and hold ticks.
+// Every note is worth one unit, and the ticks of all holds share one unit per hold
+// so each tick is worth the same.
+type Points struct {
+	notes int
+	holds int
+	ticks int
+
+	// In units of 1/(2*ticks), which keeps slips and ticks whole
+	earned int64
+}
+
+func NewPoints(notes, holds, ticks int) Points {
+	if ticks == 0 {
+		holds = 0
+	}
+	return Points{
+		notes: notes,
+		holds: holds,
+		ticks: ticks,
+	}
+}
+
+func (p *Points) scale() int64 {
+	return 2 * int64(max(p.ticks, 1))
+}
+
+// Hit adds a note hit worth the given fraction of a note, see Profile.Value
+func (p *Points) Hit(value float64) {
+	p.earned += int64(value * float64(p.scale()))
+}
+
+// Tick adds a held hold tick
+func (p *Points) Tick() {
+	p.earned += 2 * int64(p.holds)
+}
+
+func (p *Points) Score() int {
+	total := int64(p.notes+p.holds) * p.scale()
+	if total == 0 {
+		return 0
+	}
+	return int(MaxScore * p.earned / total)
+}
@@ -0,0 +1,178 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// Window is how far from its target a hit can land for a rating, in ms
+type Window struct {
+	Early int64 `json:"early"`
+	Late  int64 `json:"late"`
+}
+
+// Profile is a set of timing windows and what each rating is worth.
+// Plays are only compared against plays judged with the same profile, see ID.
+type Profile struct {
+	Name string `json:"name"`
+	Slap Window `json:"slap"`
+	Slip Window `json:"slip"`
+
+	// Fraction of a note's points each rating earns, 0 to 1
+	SlapValue float64 `json:"slap_value"`
+	SlipValue float64 `json:"slip_value"`
+}
+
+// Name of a profile made from a custom table of windows and values
+const CustomProfile = "custom"
+
+// Widest window a custom profile can have, in ms
+const maxProfileWindow = 500
+
+var (
+	// Standard is the profile plays were judged with before there were profiles.
+	// Early slips are scaled to 0.4 of the late window, inside the slap window.
+	Standard = Profile{
+		Name:      "standard",
+		Slap:      Window{Early: 60, Late: 60},
+		Slip:      Window{Early: 48, Late: 120},
+		SlapValue: 1,
+		SlipValue: 0.5,
+	}
+	Lenient = Profile{
+		Name:      "lenient",
+		Slap:      Window{Early: 80, Late: 80},
+		Slip:      Window{Early: 64, Late: 160},
+		SlapValue: 1,
+		SlipValue: 0.5,
+	}
+	Strict = Profile{
+		Name:      "strict",
+		Slap:      Window{Early: 40, Late: 40},
+		Slip:      Window{Early: 32, Late: 80},
+		SlapValue: 1,
+		SlipValue: 0.5,
+	}
+)
+
+// Profiles are the built in profiles, from the most to the least forgiving
+var Profiles = []Profile{Lenient, Standard, Strict}
+
+var ErrInvalidProfile = errors.New("invalid judgement profile")
+
+// GetProfile returns the built in profile with the given name, Standard if there is none
+func GetProfile(name string) Profile {
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return Standard
+}
+
+// JudgementID returns the profile ID a score was recorded with.
+// Scores from before there were profiles were judged with Standard.
+func JudgementID(id string) string {
+	if id == "" {
+		return Standard.Name
+	}
+	return id
+}
+
+// Builtin reports if the profile is one of Profiles, windows and values included
+func (p Profile) Builtin() bool {
+	for _, b := range Profiles {
+		if p == b {
+			return true
+		}
+	}
+	return false
+}
+
+// ID identifies the profile on leaderboards. Built in profiles go by their name,
+// anything else by a hash of its windows and values so equal tables compare.
+func (p Profile) ID() string {
+	if p.Builtin() {
+		return p.Name
+	}
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%d:%d:%d:%x:%x",
+		p.Slap.Early, p.Slap.Late, p.Slip.Early, p.Slip.Late,
+		math.Float64bits(p.SlapValue), math.Float64bits(p.SlipValue))
+	return fmt.Sprintf("%s-%08x", CustomProfile, h.Sum32())
+}
+
+// Validate checks that the windows and values of the profile can be played
+func (p Profile) Validate() error {
+	for _, w := range []Window{p.Slap, p.Slip} {
+		if w.Early < 0 || w.Late < 0 || w.Early > maxProfileWindow || w.Late > maxProfileWindow {
+			return fmt.Errorf("%w: windows must be between 0 and %dms", ErrInvalidProfile, maxProfileWindow)
+		}
+	}
+	if p.Slap.Early == 0 || p.Slap.Late == 0 {
+		return fmt.Errorf("%w: slap windows can't be empty", ErrInvalidProfile)
+	}
+	for _, v := range []float64{p.SlapValue, p.SlipValue} {
+		if !(v >= 0 && v <= 1) {
+			return fmt.Errorf("%w: values must be between 0 and 1", ErrInvalidProfile)
+		}
+	}
+	return nil
+}
+
+// Window returns the window of a rating on one side of its target
+func (p Profile) Window(r Rating, early bool) int64 {
+	var w Window
+	switch r {
+	case Slap:
+		w = p.Slap
+	case Slip:
+		w = p.Slip
+	default:
+		return 0
+	}
+	if early {
+		return w.Early
+	}
+	return w.Late
+}
+
+// Value returns the fraction of a note's points a rating earns
+func (p Profile) Value(r Rating) float64 {
+	switch r {
+	case Slap:
+		return p.SlapValue
+	case Slip:
+		return p.SlipValue
+	}
+	return 0
+}
+
+// Rate returns the rating for a hit diff (target - hit time)
+func (p Profile) Rate(diff int64) Rating {
+	d := diff
+	if d < 0 {
+		d = -d
+	}
+
+	early := IsEarly(diff)
+	if d < p.Window(Slap, early) {
+		return Slap
+	} else if d < p.Window(Slip, early) {
+		return Slip
+	}
+	return None
+}
+
+// Earliest returns how early a note can be hit, in ms
+func (p Profile) Earliest() int64 {
+	return max(p.Slap.Early, p.Slip.Early)
+}
+
+// Latest returns how late a note can be hit before it's missed, in ms
+func (p Profile) Latest() int64 {
+	return max(p.Slap.Late, p.Slip.Late)
+}
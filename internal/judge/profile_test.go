@@ -0,0 +1,83 @@
+package judge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProfileRate(t *testing.T) {
+	tests := []struct {
+		profile  Profile
+		diff     int64
+		expected Rating
+	}{
+		{Standard, 0, Slap},
+		{Standard, 59, Slap},
+		{Standard, 60, None}, // Early slips sit inside the slap window
+		{Standard, -60, Slip},
+		{Standard, -119, Slip},
+		{Standard, -120, None},
+		{Lenient, 79, Slap},
+		{Lenient, -159, Slip},
+		{Strict, 40, None},
+		{Strict, -40, Slip},
+		{Strict, -80, None},
+	}
+	for _, tt := range tests {
+		if got := tt.profile.Rate(tt.diff); got != tt.expected {
+			t.Errorf("%s %dms: expected %d, got %d", tt.profile.Name, tt.diff, tt.expected, got)
+		}
+	}
+}
+
+func TestProfileID(t *testing.T) {
+	for _, p := range Profiles {
+		if p.ID() != p.Name {
+			t.Errorf("expected %q, got %q", p.Name, p.ID())
+		}
+	}
+
+	custom := Standard
+	custom.Name = CustomProfile
+	custom.Slip.Early = 60
+	renamed := custom
+	renamed.Name = Standard.Name
+	if custom.ID() == Standard.ID() || custom.ID() != renamed.ID() {
+		t.Errorf("expected custom tables to go by their windows, got %q and %q", custom.ID(), renamed.ID())
+	}
+
+	custom.SlipValue = 0.4
+	if custom.ID() == renamed.ID() {
+		t.Errorf("expected a different value to change the ID, got %q", custom.ID())
+	}
+
+	if JudgementID("") != Standard.ID() {
+		t.Errorf("expected scores without a profile to be %q, got %q", Standard.ID(), JudgementID(""))
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range Profiles {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: %v", p.Name, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *Profile)
+	}{
+		{"negative window", func(p *Profile) { p.Slip.Early = -1 }},
+		{"huge window", func(p *Profile) { p.Slip.Late = maxProfileWindow + 1 }},
+		{"no slap window", func(p *Profile) { p.Slap.Late = 0 }},
+		{"value above one", func(p *Profile) { p.SlapValue = 1.5 }},
+		{"negative value", func(p *Profile) { p.SlipValue = -0.5 }},
+	}
+	for _, tt := range tests {
+		p := Standard
+		tt.modify(&p)
+		if err := p.Validate(); !errors.Is(err, ErrInvalidProfile) {
+			t.Errorf("%s: expected %v, got %v", tt.name, ErrInvalidProfile, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package judge
+
+import "sort"
+
+// InputEvent is a single track press or release, timed in ms from song start
+type InputEvent struct {
+	Time    int64  `json:"time"`
+	Track   string `json:"track"` // schema track name, e.g. "left_top"
+	Pressed bool   `json:"pressed"`
+}
+
+// Options are the player settings that affect judgement
+type Options struct {
+	InputOffset int64   `json:"input_offset"`
+	TravelTime  int64   `json:"travel_time"`
+	Profile     Profile `json:"profile"` // Standard when unset
+
+	Mods Mods  `json:"mods,omitempty"`
+	Seed int64 `json:"seed,omitempty"` // Order of the tracks with ModShuffle
+}
+
+// JudgementProfile returns the profile plays are judged with
+func (o Options) JudgementProfile() Profile {
+	if o.Profile == (Profile{}) {
+		return Standard
+	}
+	return o.Profile
+}
+
+// Replay is the input stream of a single play, with the settings it was played with
+type Replay struct {
+	Song       string `json:"song,omitempty"` // Hash of the song played
+	Difficulty int    `json:"difficulty,omitempty"`
+
+	// Settings that only change how the play looked and sounded
+	AudioOffset int64   `json:"audio_offset,omitempty"`
+	LaneSpeed   float64 `json:"lane_speed,omitempty"`
+
+	Options Options      `json:"options"`
+	Events  []InputEvent `json:"events"`
+
+	held     map[string]bool
+	recorded bool
+	last     int64 // Time of the latest frame recorded
+}
+
+func NewReplay(opts Options) *Replay {
+	return &Replay{
+		Options: opts,
+		Events:  make([]InputEvent, 0, 1024),
+		held:    make(map[string]bool),
+	}
+}
+
+// Record is called once per frame for each track with its current input state,
+// returning the events it added so they can be fed to a Simulation.
+// Frames before one already recorded are left out, resuming from a pause rewinds the song
+// and the replay is judged in the order it was recorded in.
+func (r *Replay) Record(time int64, track string, justPressed, held bool) []InputEvent {
+	if r.held == nil {
+		r.held = make(map[string]bool)
+	}
+	if r.recorded && time < r.last {
+		return nil
+	}
+	r.recorded, r.last = true, time
+
+	start := len(r.Events)
+	if justPressed {
+		r.Events = append(r.Events, InputEvent{Time: time, Track: track, Pressed: true})
+		r.held[track] = true
+	}
+
+	// Pressed and released within the same frame still counts as a release
+	if r.held[track] && !held {
+		r.Events = append(r.Events, InputEvent{Time: time, Track: track, Pressed: false})
+		r.held[track] = false
+	}
+	return r.Events[start:]
+}
+
+// Playback feeds the events of a replay to a Simulation as time passes
+type Playback struct {
+	events []InputEvent
+	next   int
+}
+
+func NewPlayback(r *Replay) *Playback {
+	events := make([]InputEvent, len(r.Events))
+	copy(events, r.Events)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time < events[j].Time
+	})
+	return &Playback{events: events}
+}
+
+// Next returns the events up to the given time that haven't been returned yet
+func (p *Playback) Next(now int64) []InputEvent {
+	start := p.next
+	for p.next < len(p.events) && p.events[p.next].Time <= now {
+		p.next++
+	}
+	return p.events[start:p.next]
+}
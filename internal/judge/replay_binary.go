@@ -0,0 +1,212 @@
+package judge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Replay files start with the magic and a little endian uint16 version, then:
+//
+//	song          uvarint length, bytes
+//	difficulty    uvarint
+//	audio offset  varint ms
+//	lane speed    float64 bits, little endian
+//	input offset  varint ms
+//	travel time   varint ms
+//	profile       uvarint name length, name bytes,
+//	              varint ms slap early, slap late, slip early, slip late,
+//	              float64 bits slap value, slip value, little endian
+//	modifiers     uvarint Mods, varint shuffle seed
+//	events        uvarint count, then per event:
+//	              varint ms since the previous event
+//	              byte, track index << 1 | 1 if pressed
+//
+// Track indexes are positions in trackOrder.
+const (
+	replayMagic   = "STRP"
+	ReplayVersion = 1
+)
+
+var (
+	ErrInvalidReplay     = errors.New("invalid replay")
+	ErrUnsupportedReplay = errors.New("unsupported replay version")
+)
+
+// MarshalBinary encodes the replay in the current replay file format
+func (r *Replay) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 64+len(r.Song)+3*len(r.Events))
+	buf = append(buf, replayMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, ReplayVersion)
+
+	buf = binary.AppendUvarint(buf, uint64(len(r.Song)))
+	buf = append(buf, r.Song...)
+	buf = binary.AppendUvarint(buf, uint64(max(r.Difficulty, 0)))
+	buf = binary.AppendVarint(buf, r.AudioOffset)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(r.LaneSpeed))
+	buf = binary.AppendVarint(buf, r.Options.InputOffset)
+	buf = binary.AppendVarint(buf, r.Options.TravelTime)
+
+	profile := r.Options.Profile
+	buf = binary.AppendUvarint(buf, uint64(len(profile.Name)))
+	buf = append(buf, profile.Name...)
+	buf = binary.AppendVarint(buf, profile.Slap.Early)
+	buf = binary.AppendVarint(buf, profile.Slap.Late)
+	buf = binary.AppendVarint(buf, profile.Slip.Early)
+	buf = binary.AppendVarint(buf, profile.Slip.Late)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(profile.SlapValue))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(profile.SlipValue))
+
+	buf = binary.AppendUvarint(buf, uint64(r.Options.Mods))
+	buf = binary.AppendVarint(buf, r.Options.Seed)
+
+	buf = binary.AppendUvarint(buf, uint64(len(r.Events)))
+	last := int64(0)
+	for _, e := range r.Events {
+		track := trackIndex(e.Track)
+		if track < 0 {
+			return nil, fmt.Errorf("%w: unknown track %q", ErrInvalidReplay, e.Track)
+		}
+
+		b := byte(track) << 1
+		if e.Pressed {
+			b |= 1
+		}
+		buf = binary.AppendVarint(buf, e.Time-last)
+		buf = append(buf, b)
+		last = e.Time
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a replay file of the current version
+func (r *Replay) UnmarshalBinary(data []byte) error {
+	header := len(replayMagic) + 2
+	if len(data) < header || string(data[:len(replayMagic)]) != replayMagic {
+		return ErrInvalidReplay
+	}
+	version := binary.LittleEndian.Uint16(data[len(replayMagic):header])
+	if version != ReplayVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedReplay, version)
+	}
+
+	d := &replayDecoder{r: bytes.NewReader(data[header:])}
+	replay := &Replay{}
+	replay.Song = string(d.bytes())
+	replay.Difficulty = int(d.uvarint())
+	replay.AudioOffset = d.varint()
+	replay.LaneSpeed = math.Float64frombits(d.uint64())
+	replay.Options.InputOffset = d.varint()
+	replay.Options.TravelTime = d.varint()
+
+	profile := &replay.Options.Profile
+	profile.Name = string(d.bytes())
+	profile.Slap.Early = d.varint()
+	profile.Slap.Late = d.varint()
+	profile.Slip.Early = d.varint()
+	profile.Slip.Late = d.varint()
+	profile.SlapValue = math.Float64frombits(d.uint64())
+	profile.SlipValue = math.Float64frombits(d.uint64())
+
+	mods := d.uvarint()
+	if mods > math.MaxUint32 {
+		return fmt.Errorf("%w: modifiers %#x", ErrInvalidReplay, mods)
+	}
+	replay.Options.Mods = Mods(mods)
+	replay.Options.Seed = d.varint()
+
+	// Every event takes at least two bytes
+	count := d.uvarint()
+	if count > uint64(d.r.Len()/2) {
+		return fmt.Errorf("%w: %d events in %d bytes", ErrInvalidReplay, count, d.r.Len())
+	}
+	replay.Events = make([]InputEvent, 0, count)
+	last := int64(0)
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		last += d.varint()
+		b := d.byte()
+		track := int(b >> 1)
+		if track >= len(trackOrder) {
+			return fmt.Errorf("%w: unknown track %d", ErrInvalidReplay, track)
+		}
+		replay.Events = append(replay.Events, InputEvent{
+			Time:    last,
+			Track:   trackOrder[track],
+			Pressed: b&1 != 0,
+		})
+	}
+	if d.err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReplay, d.err)
+	}
+
+	*r = *replay
+	return nil
+}
+
+func trackIndex(track string) int {
+	for i, name := range trackOrder {
+		if name == track {
+			return i
+		}
+	}
+	return -1
+}
+
+// replayDecoder reads replay fields, keeping the first error
+type replayDecoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *replayDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *replayDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *replayDecoder) uint64() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var b [8]byte
+	_, d.err = io.ReadFull(d.r, b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+func (d *replayDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.err = err
+	return b
+}
+
+func (d *replayDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(d.r.Len()) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, b)
+	return b
+}
@@ -0,0 +1,90 @@
+package judge
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+func TestReplayBinaryRoundTrip(t *testing.T) {
+	profile := Strict
+	profile.Name = CustomProfile
+	profile.SlipValue = 0.25
+	replay := NewReplay(Options{
+		InputOffset: -12,
+		TravelTime:  3333,
+		Profile:     profile,
+		Mods:        ModShuffle | ModHidden | ModAllTaps,
+		Seed:        -42,
+	})
+	replay.Song = "1e35451d819b234a457b53e331fb5844afb7510073bd760b90b9042700fbcea4"
+	replay.Difficulty = 5
+	replay.AudioOffset = 40
+	replay.LaneSpeed = 1.5
+	replay.Events = []InputEvent{
+		{Time: -200, Track: schema.TrackLeftTop, Pressed: true},
+		{Time: -200, Track: schema.TrackLeftTop, Pressed: false},
+		{Time: 1000, Track: schema.TrackCenterTop, Pressed: true},
+		{Time: 1000, Track: schema.TrackRightBottom, Pressed: true},
+		{Time: 987654, Track: schema.TrackCenterTop, Pressed: false},
+		{Time: 950, Track: schema.TrackRightBottom, Pressed: false}, // out of order still round trips
+	}
+
+	data, err := replay.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Replay
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got.Song != replay.Song || got.Difficulty != replay.Difficulty ||
+		got.AudioOffset != replay.AudioOffset || got.LaneSpeed != replay.LaneSpeed {
+		t.Errorf("expected %+v, got %+v", replay, got)
+	}
+	if got.Options != replay.Options {
+		t.Errorf("expected options %+v, got %+v", replay.Options, got.Options)
+	}
+	if !reflect.DeepEqual(got.Events, replay.Events) {
+		t.Errorf("expected events %v, got %v", replay.Events, got.Events)
+	}
+}
+
+func TestReplayBinaryInvalid(t *testing.T) {
+	replay := NewReplay(Options{})
+	replay.Events = []InputEvent{{Time: 1000, Track: schema.TrackLeftTop, Pressed: true}}
+	data, err := replay.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := append([]byte{}, data...)
+	other[len(replayMagic)] = ReplayVersion + 1
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", nil, ErrInvalidReplay},
+		{"not a replay", []byte("{\"events\": []}"), ErrInvalidReplay},
+		{"truncated", data[:len(data)-1], ErrInvalidReplay},
+		{"other version", other, ErrUnsupportedReplay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Replay
+			if err := r.UnmarshalBinary(tt.data); !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	replay.Events[0].Track = "nowhere"
+	if _, err := replay.MarshalBinary(); !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("expected %v for an unknown track, got %v", ErrInvalidReplay, err)
+	}
+}
@@ -0,0 +1,76 @@
+package judge
+
+// Judgement is a note hit or miss, in the order they were judged
+type Judgement struct {
+	Note   *Note
+	Diff   int64 // target - hit time, before the input offset
+	Rating Rating
+}
+
+// Score is the running tally of a simulation
+type Score struct {
+	TotalNotes int
+	Slap       int
+	Slip       int
+	Slop       int
+	Early      int
+	Late       int
+	Combo      int
+	MaxCombo   int
+
+	HoldIntervals    int // Hold intervals judged so far
+	HoldIntervalsHit int // Hold intervals that were held
+
+	Mods Mods // Modifiers the play is judged with, see Total
+
+	Judgements []Judgement
+	points     Points
+}
+
+// Total is the score out of MaxScore, with the multiplier of the modifiers applied
+func (s *Score) Total() int {
+	return s.Mods.score(s.points.Score())
+}
+
+func (s *Score) Accuracy() float64 {
+	if s.TotalNotes == 0 {
+		return 0
+	}
+	return float64(s.Slap) / float64(s.TotalNotes)
+}
+
+func (s *Score) hit(n *Note, diff int64, rating Rating, value float64) {
+	switch rating {
+	case Slap:
+		s.Slap++
+	case Slip:
+		s.Slip++
+	}
+	s.Combo++
+	if s.Combo > s.MaxCombo {
+		s.MaxCombo = s.Combo
+	}
+	if IsEarly(diff) {
+		s.Early++
+	} else if IsLate(diff) {
+		s.Late++
+	}
+	s.points.Hit(value)
+	s.Judgements = append(s.Judgements, Judgement{Note: n, Diff: n.Target - n.HitTime, Rating: rating})
+}
+
+func (s *Score) miss(n *Note) {
+	s.Slop++
+	s.Combo = 0
+	s.Judgements = append(s.Judgements, Judgement{Note: n, Rating: Slop})
+}
+
+func (s *Score) holdInterval(hit bool) {
+	s.HoldIntervals++
+	if hit {
+		s.HoldIntervalsHit++
+		s.points.Tick()
+	} else {
+		s.Combo = 0
+	}
+}
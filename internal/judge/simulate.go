@@ -0,0 +1,455 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/liqmix/slaptrax/internal/beats"
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+// Default note travel time in ms at lane speed 1.0
+const DefaultTravelTime = 5000
+
+// Most input events a replay can have, per note of its chart and in total on top of that.
+// Every note takes a press and a release, the rest leaves room for mashing.
+const (
+	maxReplayEventsPerNote = 32
+	maxReplayEventsExtra   = 1024
+)
+
+// Time in ms input can be recorded before and after the notes of a chart can be judged
+const replayWindowSlack = 1000
+
+// Track update order used by the game, order is critical here
+var trackOrder = []string{
+	schema.TrackLeftBottom,
+	schema.TrackLeftTop,
+	schema.TrackRightBottom,
+	schema.TrackRightTop,
+	schema.TrackCenterBottom,
+	schema.TrackCenterTop,
+}
+
+// Note is a note of a chart and how it has been judged so far
+type Note struct {
+	Target        int64   // ms from start of song it should be played
+	TargetRelease int64   // ms the note should be held until, 0 for taps
+	Intervals     []int64 // Times a hold is checked at, see HoldIntervals
+
+	Hold          bool  // Judged as a hold, false for every note when holds are disabled
+	HitTime       int64 // ms the note was hit
+	ReleaseTime   int64 // ms the note was released
+	Rating        Rating
+	IntervalsHit  []bool
+	LastChecked   int  // Intervals judged so far
+	MissedInitial bool // Hold that can still be held after missing its start
+	Inactive      bool // Released or missed hold that can be reactivated
+	Active        bool // Hold that is being held
+	Done          bool // Nothing left to judge
+}
+
+func NewNote(target, targetRelease int64) *Note {
+	return &Note{
+		Target:        target,
+		TargetRelease: targetRelease,
+		Rating:        None,
+	}
+}
+
+// Reset clears the judged state of the note
+func (n *Note) Reset(holds bool) {
+	n.Hold = holds && n.TargetRelease > 0
+	n.HitTime = 0
+	n.ReleaseTime = 0
+	n.Rating = None
+	n.IntervalsHit = make([]bool, len(n.Intervals))
+	n.LastChecked = 0
+	n.MissedInitial = false
+	n.Inactive = false
+	n.Active = false
+	n.Done = false
+}
+
+func (n *Note) WasHit() bool {
+	return n.Rating != None && n.Rating != Slop
+}
+
+func (n *Note) InWindow(start, end int64) bool {
+	if n.Target >= start && n.Target <= end {
+		return true
+	}
+	return n.TargetRelease >= start && n.TargetRelease <= end
+}
+
+type simTrack struct {
+	allNotes    []*Note
+	activeNotes []*Note
+	nextNote    int
+
+	active      bool
+	staleActive bool
+
+	justPressed bool
+	held        bool
+}
+
+// Simulation judges the notes of a chart against input one step at a time.
+// Simulate runs it headless over a replay and state.Play runs it live,
+// so a play is judged the same way on the client and the service.
+type Simulation struct {
+	opts   Options
+	tracks map[string]*simTrack
+	score  *Score
+}
+
+// NewSimulation starts judging notes, keyed by the schema track name they're played on,
+// see Options.Arrange. The judged state of every note is reset.
+func NewSimulation(notes map[string][]*Note, opts Options) (*Simulation, error) {
+	if opts.TravelTime <= 0 {
+		opts.TravelTime = DefaultTravelTime
+	}
+	opts.Profile = opts.JudgementProfile()
+	if err := opts.Profile.Validate(); err != nil {
+		return nil, err
+	}
+	mods := opts.Mods
+	if err := mods.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Simulation{
+		opts:   opts,
+		tracks: make(map[string]*simTrack),
+		score:  &Score{Mods: mods},
+	}
+	for _, name := range trackOrder {
+		s.tracks[name] = &simTrack{}
+	}
+
+	holds, ticks := 0, 0
+	for name, trackNotes := range notes {
+		t, ok := s.tracks[name]
+		if !ok {
+			return nil, errors.New("invalid track name: " + name)
+		}
+
+		t.allNotes = make([]*Note, len(trackNotes))
+		copy(t.allNotes, trackNotes)
+		sort.SliceStable(t.allNotes, func(i, j int) bool {
+			return t.allNotes[i].Target < t.allNotes[j].Target
+		})
+
+		for _, n := range t.allNotes {
+			n.Reset(!mods.Has(ModAllTaps))
+			if n.Hold {
+				holds++
+				ticks += len(n.Intervals)
+			}
+		}
+		s.score.TotalNotes += len(t.allNotes)
+	}
+	s.score.points = NewPoints(s.score.TotalNotes, holds, ticks)
+	return s, nil
+}
+
+func (s *Simulation) Score() *Score {
+	return s.score
+}
+
+// Input applies a press or release, judged on the next Step
+func (s *Simulation) Input(e InputEvent) error {
+	t, ok := s.tracks[e.Track]
+	if !ok {
+		return errors.New("invalid track name in input: " + e.Track)
+	}
+	if e.Pressed {
+		t.justPressed = true
+		t.held = true
+	} else {
+		t.held = false
+	}
+	return nil
+}
+
+// Pressed reports if a track is pressed, stale presses no longer hit taps
+func (s *Simulation) Pressed(track string) (active, stale bool) {
+	if t, ok := s.tracks[track]; ok {
+		return t.active, t.staleActive
+	}
+	return false, false
+}
+
+// Step judges every track at the given time
+func (s *Simulation) Step(now int64) {
+	for _, name := range trackOrder {
+		t := s.tracks[name]
+		s.updateTrack(t, now)
+		t.justPressed = false
+	}
+}
+
+// End returns the last time anything in the simulation can be judged
+func (s *Simulation) End() int64 {
+	end := int64(0)
+	for _, t := range s.tracks {
+		for _, n := range t.allNotes {
+			end = max(end, n.Target, n.TargetRelease)
+		}
+	}
+	return end + s.opts.Profile.Latest() + 1
+}
+
+// Window returns the first and last time input is recorded, around when it can change a judgement.
+// Replays with input outside of it are invalid.
+func (s *Simulation) Window() (start, end int64) {
+	first := int64(0)
+	for _, t := range s.tracks {
+		for _, n := range t.allNotes {
+			first = min(first, n.Target)
+		}
+	}
+	offset := s.opts.InputOffset
+	start = first + min(0, offset) - s.opts.Profile.Earliest() - replayWindowSlack
+	end = s.End() + max(0, offset) + replayWindowSlack
+	return start, end
+}
+
+// EventLimit returns the most input events a replay of the notes can have,
+// input past it is never recorded and replays with more are invalid
+func (s *Simulation) EventLimit() int {
+	return s.score.TotalNotes*maxReplayEventsPerNote + maxReplayEventsExtra
+}
+
+// NewNotes creates the notes of a chart, keyed by schema track name
+func NewNotes(chart *schema.ChartDataV2, tempo *beats.TempoMap) (map[string][]*Note, error) {
+	notes := make(map[string][]*Note)
+	for trackName, data := range chart.Tracks {
+		if trackIndex(trackName) < 0 {
+			return nil, errors.New("invalid track name: " + trackName)
+		}
+
+		for _, d := range data {
+			// Multi notes are kept on the track they were charted in,
+			// the same as the song parser does
+			count := 1
+			if d.Type == schema.NoteTypeMulti {
+				count = len(d.Tracks)
+			}
+			for i := 0; i < count; i++ {
+				n := NewNote(d.Time, 0)
+				if d.Type != schema.NoteTypeTap && d.Duration > 0 {
+					n.TargetRelease = d.Time + d.Duration
+					n.Intervals = HoldIntervals(tempo, n.Target, n.TargetRelease)
+				}
+				notes[trackName] = append(notes[trackName], n)
+			}
+		}
+	}
+	return notes, nil
+}
+
+// Simulate replays an input stream against a chart of a song with the given bpm
+func Simulate(chart *schema.ChartDataV2, bpm int, replay *Replay) (*Score, error) {
+	if chart == nil || replay == nil {
+		return nil, errors.New("missing chart or replay")
+	}
+
+	notes, err := NewNotes(chart, NewTempoMap(bpm, chart.Events))
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSimulation(replay.Options.Arrange(notes), replay.Options)
+	if err != nil {
+		return nil, err
+	}
+	if s.score.TotalNotes == 0 {
+		return nil, errors.New("chart has no notes")
+	}
+
+	// Stepped once per ms, so the replay can't make the simulation run past its chart
+	if len(replay.Events) > s.EventLimit() {
+		return nil, fmt.Errorf("%w: %d events for %d notes", ErrInvalidReplay, len(replay.Events), s.score.TotalNotes)
+	}
+	start, end := s.Window()
+	for _, e := range replay.Events {
+		if e.Time < start || e.Time > end {
+			return nil, fmt.Errorf("%w: event at %dms outside of %dms to %dms", ErrInvalidReplay, e.Time, start, end)
+		}
+	}
+
+	playback := NewPlayback(replay)
+	for now := start; now <= end; now++ {
+		for _, e := range playback.Next(now) {
+			if err := s.Input(e); err != nil {
+				return nil, err
+			}
+		}
+		s.Step(now)
+	}
+	return s.score, nil
+}
+
+func (s *Simulation) updateTrack(t *simTrack, now int64) {
+	latest := s.opts.Profile.Latest()
+	if !t.active && !t.staleActive && t.justPressed {
+		t.active = true
+	}
+	if (t.staleActive || t.active) && !t.held {
+		t.active = false
+		t.staleActive = false
+	}
+
+	notes := make([]*Note, 0, len(t.activeNotes))
+	for _, n := range t.activeNotes {
+		if n.Hold && (n.WasHit() || n.MissedInitial) {
+			s.checkHoldProgress(n, now)
+		}
+
+		if n.Hold {
+			if !n.WasHit() && !n.MissedInitial && now >= n.Target+latest {
+				n.MissedInitial = true
+				n.Inactive = true
+				s.miss(n)
+			}
+
+			if t.active {
+				if s.canReactivate(n, now) {
+					n.Active = true
+					n.Inactive = false
+					n.ReleaseTime = 0
+				}
+				if !n.WasHit() && n.Target-latest <= now && now <= n.Target+latest {
+					s.hit(n, now)
+				}
+				if (n.WasHit() && !n.Inactive) || s.canReactivate(n, now) {
+					n.Active = true
+					if n.MissedInitial {
+						n.Inactive = false
+					}
+				}
+			} else {
+				if n.Active {
+					s.release(n, now)
+				}
+				n.Active = false
+				n.Inactive = true
+			}
+
+			// Past its release with every interval judged
+			if now > n.TargetRelease+latest && n.LastChecked >= len(n.Intervals) {
+				n.Done = true
+				continue
+			}
+			notes = append(notes, n)
+			continue
+		}
+
+		if n.WasHit() {
+			n.Done = true
+			continue
+		}
+		if t.active && !t.staleActive && s.hit(n, now) {
+			t.staleActive = true
+			n.Done = true
+			continue
+		}
+		if now < n.Target+latest {
+			notes = append(notes, n)
+			continue
+		}
+		s.miss(n)
+		n.Done = true
+	}
+
+	for ; t.nextNote < len(t.allNotes); t.nextNote++ {
+		n := t.allNotes[t.nextNote]
+		if n.Target > now+s.opts.TravelTime {
+			break
+		}
+		notes = append(notes, n)
+	}
+	t.activeNotes = notes
+
+	if t.active && !t.staleActive {
+		for _, n := range t.activeNotes {
+			if n.InWindow(now-s.opts.Profile.Earliest(), now+latest) {
+				return
+			}
+		}
+		t.staleActive = true
+	}
+}
+
+func (s *Simulation) hit(n *Note, now int64) bool {
+	if n.WasHit() {
+		return false
+	}
+
+	diff := n.Target - now + s.opts.InputOffset
+	rating := s.opts.Profile.Rate(diff)
+	if rating == None {
+		return false
+	}
+	n.HitTime = now
+	n.Rating = rating
+	s.score.hit(n, diff, rating, s.opts.Profile.Value(rating))
+	return true
+}
+
+func (s *Simulation) miss(n *Note) {
+	if n.Rating != None {
+		return
+	}
+	n.Rating = Slop
+	if n.Hold {
+		n.MissedInitial = true
+	}
+	s.score.miss(n)
+}
+
+func (s *Simulation) release(n *Note, now int64) {
+	// Offset the same way as hits, see hit
+	current := now - s.opts.InputOffset
+	if n.ReleaseTime == 0 {
+		n.ReleaseTime = current
+	}
+
+	for i := n.LastChecked; i < len(n.Intervals); i++ {
+		if n.Intervals[i] > current {
+			break
+		}
+		n.IntervalsHit[i] = true
+		s.score.holdInterval(true)
+		n.LastChecked = i + 1
+	}
+}
+
+func (s *Simulation) canReactivate(n *Note, now int64) bool {
+	if !n.Hold {
+		return false
+	}
+	if !n.WasHit() && !n.MissedInitial {
+		return false
+	}
+
+	withinDuration := now <= n.TargetRelease+s.opts.Profile.Latest()
+	hasRemaining := len(n.Intervals) == 0 || n.LastChecked < len(n.Intervals)
+	if n.MissedInitial && !n.Active {
+		return withinDuration && hasRemaining && now >= n.Target
+	}
+	return n.Inactive && withinDuration && hasRemaining
+}
+
+func (s *Simulation) checkHoldProgress(n *Note, now int64) {
+	for i := n.LastChecked; i < len(n.Intervals); i++ {
+		if now < n.Intervals[i] {
+			break
+		}
+		hit := n.Active && (n.WasHit() || n.MissedInitial)
+		n.IntervalsHit[i] = hit
+		s.score.holdInterval(hit)
+		n.LastChecked = i + 1
+	}
+}
@@ -0,0 +1,364 @@
+package judge
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/liqmix/slaptrax/internal/beats"
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+func TestBundledChartsSumToMaxScore(t *testing.T) {
+	files, err := filepath.Glob("../assets/songs/*/song.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no bundled songs found")
+	}
+
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		song, err := schema.FromJSON(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+
+		for name, chart := range song.Charts {
+			t.Run(filepath.Base(filepath.Dir(file))+"/"+name, func(t *testing.T) {
+				notes, err := NewNotes(&chart, NewTempoMap(song.Metadata.BPM, chart.Events))
+				if err != nil {
+					t.Fatal(err)
+				}
+				result, err := Simulate(&chart, song.Metadata.BPM, Autoplay(notes, Options{}, Humanize{}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := result.Total(); got != MaxScore {
+					t.Errorf("expected %d, got %d", MaxScore, got)
+				}
+				if result.Slop > 0 {
+					t.Errorf("expected no slops, got %d", result.Slop)
+				}
+			})
+		}
+	}
+}
+
+func TestPointsSumToMaxScore(t *testing.T) {
+	tests := []struct {
+		notes, holds, ticks int
+	}{
+		{1, 0, 0},
+		{3, 0, 0},
+		{7, 1, 1},
+		{7, 3, 11},
+		{881, 44, 997},
+		{5, 5, 0}, // holds too short to tick count as taps
+	}
+	for _, tt := range tests {
+		p := NewPoints(tt.notes, tt.holds, tt.ticks)
+		for range tt.notes {
+			p.Hit(Standard.SlapValue)
+		}
+		for range tt.ticks {
+			p.Tick()
+		}
+		if got := p.Score(); got != MaxScore {
+			t.Errorf("%+v: expected %d, got %d", tt, MaxScore, got)
+		}
+	}
+
+	// Slips are worth half
+	p := NewPoints(2, 0, 0)
+	p.Hit(Standard.SlipValue)
+	p.Hit(Standard.SlipValue)
+	if got := p.Score(); got != MaxScore/2 {
+		t.Errorf("expected %d, got %d", MaxScore/2, got)
+	}
+}
+
+func TestHoldIntervals(t *testing.T) {
+	// 1/16 notes are 125ms at 120 bpm, then 62.5ms at 240 bpm from 2000ms
+	tempo := beats.NewTempoMap(120, []beats.TempoChange{{Time: 2000, BPM: 240}})
+
+	tests := []struct {
+		name            string
+		target, release int64
+		expected        []int64
+	}{
+		{"on the grid", 0, 500, []int64{125, 250, 375, 500}},
+		{"off the grid", 60, 400, []int64{125, 250, 375}},
+		{"rounded onto the grid", 124, 499, []int64{250, 375, 499}},
+		{"too short for the grid", 130, 240, []int64{240}},
+		{"across a tempo change", 1750, 2250, []int64{1875, 2000, 2063, 2125, 2188, 2250}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HoldIntervals(tempo, tt.target, tt.release)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Fatalf("expected %v, got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	const lb, rb = schema.TrackLeftBottom, schema.TrackRightBottom
+	tap := func(time int64) schema.NoteData {
+		return schema.NoteData{Time: time, Type: schema.NoteTypeTap}
+	}
+	// Ticks at 1125, 1250, 1375 and 1500 at 120 bpm
+	hold := schema.NoteData{Time: 1000, Type: schema.NoteTypeHold, Duration: 500}
+	press := func(track string, time int64) InputEvent {
+		return InputEvent{Time: time, Track: track, Pressed: true}
+	}
+	release := func(track string, time int64) InputEvent {
+		return InputEvent{Time: time, Track: track, Pressed: false}
+	}
+
+	tests := []struct {
+		name     string
+		tracks   map[string][]schema.NoteData
+		opts     Options
+		events   []InputEvent
+		slap     int
+		slip     int
+		slop     int
+		ticksHit int
+		total    int
+	}{
+		{
+			name:   "tap on time",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			events: []InputEvent{press(lb, 1000), release(lb, 1030)},
+			slap:   1,
+			total:  MaxScore,
+		},
+		{
+			name:   "tap late",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			events: []InputEvent{press(lb, 1080), release(lb, 1100)},
+			slip:   1,
+			total:  MaxScore / 2,
+		},
+		{
+			name:   "tap late on lenient",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			opts:   Options{Profile: Lenient},
+			events: []InputEvent{press(lb, 1070), release(lb, 1100)},
+			slap:   1,
+			total:  MaxScore,
+		},
+		{
+			name:   "tap late on strict",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			opts:   Options{Profile: Strict},
+			events: []InputEvent{press(lb, 1090), release(lb, 1100)},
+			slop:   1,
+		},
+		{
+			name:   "tap released before its window",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			events: []InputEvent{press(lb, 900), release(lb, 930)},
+			slop:   1,
+		},
+		{
+			name:   "tap not pressed",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			slop:   1,
+		},
+		{
+			name:   "input offset",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			opts:   Options{InputOffset: 80},
+			events: []InputEvent{press(lb, 1080), release(lb, 1100)},
+			slap:   1,
+			total:  MaxScore,
+		},
+		{
+			name:   "chord",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}, rb: {tap(1000)}},
+			events: []InputEvent{press(lb, 1000), press(rb, 1000), release(lb, 1030), release(rb, 1030)},
+			slap:   2,
+			total:  MaxScore,
+		},
+		{
+			name:   "chord half pressed",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}, rb: {tap(1000)}},
+			events: []InputEvent{press(lb, 1000), release(lb, 1030)},
+			slap:   1,
+			slop:   1,
+			total:  MaxScore / 2,
+		},
+		{
+			name:   "one press hits one tap",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000), tap(1010)}},
+			events: []InputEvent{press(lb, 1000), release(lb, 1200)},
+			slap:   1,
+			slop:   1,
+			total:  MaxScore / 2,
+		},
+		{
+			name:     "hold held to release",
+			tracks:   map[string][]schema.NoteData{lb: {hold}},
+			events:   []InputEvent{press(lb, 1000), release(lb, 1500)},
+			slap:     1,
+			ticksHit: 4,
+			total:    MaxScore,
+		},
+		{
+			name:     "hold released early",
+			tracks:   map[string][]schema.NoteData{lb: {hold}},
+			events:   []InputEvent{press(lb, 1000), release(lb, 1200)},
+			slap:     1,
+			ticksHit: 1,
+			total:    MaxScore * 10 / 16,
+		},
+		{
+			name:     "hold reactivated",
+			tracks:   map[string][]schema.NoteData{lb: {hold}},
+			events:   []InputEvent{press(lb, 1000), release(lb, 1200), press(lb, 1300), release(lb, 1500)},
+			slap:     1,
+			ticksHit: 3,
+			total:    MaxScore * 14 / 16,
+		},
+		{
+			name:     "hold start missed",
+			tracks:   map[string][]schema.NoteData{lb: {hold}},
+			events:   []InputEvent{press(lb, 1200), release(lb, 1500)},
+			slop:     1,
+			ticksHit: 3,
+			total:    MaxScore * 6 / 16,
+		},
+		{
+			name:   "hold not pressed",
+			tracks: map[string][]schema.NoteData{lb: {hold}},
+			slop:   1,
+		},
+		{
+			name:   "all taps",
+			tracks: map[string][]schema.NoteData{lb: {hold}},
+			opts:   Options{Mods: ModAllTaps},
+			events: []InputEvent{press(lb, 1000), release(lb, 1030)},
+			slap:   1,
+			total:  MaxScore * 9 / 10,
+		},
+		{
+			name:   "mirrored",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			opts:   Options{Mods: ModMirror},
+			events: []InputEvent{press(rb, 1000), release(rb, 1030)},
+			slap:   1,
+			total:  MaxScore,
+		},
+		{
+			name:   "mirrored pressed as charted",
+			tracks: map[string][]schema.NoteData{lb: {tap(1000)}},
+			opts:   Options{Mods: ModMirror},
+			events: []InputEvent{press(lb, 1000), release(lb, 1030)},
+			slop:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay := NewReplay(tt.opts)
+			replay.Events = tt.events
+			score, err := Simulate(&schema.ChartDataV2{Tracks: tt.tracks}, 120, replay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if score.Slap != tt.slap || score.Slip != tt.slip || score.Slop != tt.slop {
+				t.Errorf("expected %d/%d/%d slap/slip/slop, got %d/%d/%d",
+					tt.slap, tt.slip, tt.slop, score.Slap, score.Slip, score.Slop)
+			}
+			if score.HoldIntervalsHit != tt.ticksHit {
+				t.Errorf("expected %d ticks held, got %d", tt.ticksHit, score.HoldIntervalsHit)
+			}
+			if got := score.Total(); got != tt.total {
+				t.Errorf("expected score %d, got %d", tt.total, got)
+			}
+		})
+	}
+}
+
+func TestSimulateInvalidTrack(t *testing.T) {
+	chart := &schema.ChartDataV2{Tracks: map[string][]schema.NoteData{
+		schema.TrackLeftBottom: {{Time: 1000, Type: schema.NoteTypeTap}},
+	}}
+	replay := NewReplay(Options{})
+	replay.Events = []InputEvent{{Time: 1000, Track: "nowhere", Pressed: true}}
+	if _, err := Simulate(chart, 120, replay); err == nil {
+		t.Error("expected an error for an unknown track")
+	}
+}
+
+func TestSimulateReplayLimits(t *testing.T) {
+	chart := &schema.ChartDataV2{Tracks: map[string][]schema.NoteData{
+		schema.TrackLeftBottom: {{Time: 1000, Type: schema.NoteTypeTap}},
+	}}
+	press := func(time int64) InputEvent {
+		return InputEvent{Time: time, Track: schema.TrackLeftBottom, Pressed: true}
+	}
+
+	tests := []struct {
+		name   string
+		events []InputEvent
+	}{
+		{"long after the chart", []InputEvent{press(2e9)}},
+		{"long before the chart", []InputEvent{press(-2e9)}},
+		{"too many events", make([]InputEvent, maxReplayEventsPerNote+maxReplayEventsExtra+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay := NewReplay(Options{})
+			replay.Events = tt.events
+			if _, err := Simulate(chart, 120, replay); !errors.Is(err, ErrInvalidReplay) {
+				t.Errorf("expected %v, got %v", ErrInvalidReplay, err)
+			}
+		})
+	}
+}
+
+func TestSimulateRewoundReplay(t *testing.T) {
+	const lb = schema.TrackLeftBottom
+	chart := &schema.ChartDataV2{Tracks: map[string][]schema.NoteData{
+		lb: {{Time: 1000, Type: schema.NoteTypeTap}, {Time: 2000, Type: schema.NoteTypeTap}},
+	}}
+
+	// Recorded a frame every 10ms, each press held for 30ms
+	replay := NewReplay(Options{})
+	play := func(from, to int64, presses ...int64) {
+		for now := from; now <= to; now += 10 {
+			pressed, held := false, false
+			for _, p := range presses {
+				pressed = pressed || now == p
+				held = held || (now >= p && now < p+30)
+			}
+			replay.Record(now, lb, pressed, held)
+		}
+	}
+	play(0, 1500, 1100)
+
+	// Paused, then resumed with the song rewound to before the first note.
+	// Pressing it on time again doesn't change the late slip already judged.
+	play(-500, 2500, 1000, 2000)
+
+	score, err := Simulate(chart, 120, replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Slap != 1 || score.Slip != 1 {
+		t.Errorf("expected 1/1 slap/slip, got %d/%d", score.Slap, score.Slip)
+	}
+}
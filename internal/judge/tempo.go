@@ -0,0 +1,52 @@
+package judge
+
+import (
+	"math"
+
+	"github.com/liqmix/slaptrax/internal/beats"
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+// Hold notes are checked every 1/16 note
+const holdSubdivision = 4
+
+// Distance in ms a note can be from a grid line and still be on it
+const gridTolerance = 1
+
+// NewTempoMap builds the tempo map of a chart from the song bpm and its bpm_change events
+func NewTempoMap(bpm int, events []schema.EventData) *beats.TempoMap {
+	changes := make([]beats.TempoChange, 0)
+	for _, e := range events {
+		if e.Type != schema.EventTypeBPMChange {
+			continue
+		}
+		if value, ok := e.Properties["bpm"].(float64); ok {
+			changes = append(changes, beats.TempoChange{Time: e.Time, BPM: value})
+		}
+	}
+	return beats.NewTempoMap(float64(bpm), changes)
+}
+
+// HoldIntervals returns the times a hold note is checked at, on every 1/16 note
+// of the chart's beat grid after its start up to its release. Holds too short
+// to reach the grid are checked once at their release.
+// Shared with types.Note so the client and the simulation agree.
+func HoldIntervals(tempo *beats.TempoMap, target, release int64) []int64 {
+	intervals := make([]int64, 0)
+	for tick := math.Floor(tempo.BeatAt(target)*holdSubdivision) + 1; ; tick++ {
+		time := tempo.TimeAt(tick / holdSubdivision)
+
+		// Notes are charted to the ms, ticks rounding onto either end belong to it
+		if time > release+gridTolerance {
+			break
+		}
+		if time <= target+gridTolerance {
+			continue
+		}
+		intervals = append(intervals, min(time, release))
+	}
+	if len(intervals) == 0 {
+		intervals = append(intervals, release)
+	}
+	return intervals
+}
@@ -3,23 +3,29 @@ package l
 const (
 	LOCALE = "locale" // The current locale name in locale language
 
-	TITLE        = "title"
-	EXIT         = "exit"
-	BACK         = "back"
-	SAVE         = "save"
-	OK           = "ok"
-	CANCEL       = "cancel"
-	CONTINUE     = "continue"
-	OFF          = "off"
-	ON           = "on"
-	GUEST        = "guest"
-	LOADING      = "loading"
-	CHART        = "chart"
-	CENTER       = "center"
-	CORNER       = "corner"
-	DIFFICULTIES = "difficulties"
-	WELCOME      = "welcome"
-	LEADERBOARD  = "leaderboard"
+	TITLE         = "title"
+	EXIT          = "exit"
+	BACK          = "back"
+	SAVE          = "save"
+	OK            = "ok"
+	CANCEL        = "cancel"
+	CONTINUE      = "continue"
+	OFF           = "off"
+	ON            = "on"
+	GUEST         = "guest"
+	LOADING       = "loading"
+	CHART         = "chart"
+	CENTER        = "center"
+	CORNER        = "corner"
+	DIFFICULTIES  = "difficulties"
+	WELCOME       = "welcome"
+	LEADERBOARD   = "leaderboard"
+	PERSONAL_BEST = "personal.best"
+	PLAYS         = "plays"
+	PROFILE       = "profile"
+	PROFILE_NEW   = "profile.new"
+	AUTOPLAY      = "autoplay"
+	UNRANKED      = "unranked"
 
 	// Actions
 	ACTION_BACK   = "action.back"
@@ -47,14 +53,17 @@ const (
 	STATE_HOW_TO_PLAY          = "state.howtoplay"
 
 	// Login
-	LOGIN_TEXT_OFFLINE = "login.text.offline"
-	LOGIN_TEXT_ONLINE  = "login.text.online"
-	LOGIN_SAVE_LOCAL   = "login.save.local"
-	LOGIN_CONTINUE     = "login.continue"
-	LOGIN_LOGIN        = "login.login"
-	LOGIN_LOGOUT       = "login.logout"
-	LOGIN_USERNAME     = "login.username"
-	LOGIN_PASSWORD     = "login.password"
+	LOGIN_TEXT_OFFLINE      = "login.text.offline"
+	LOGIN_TEXT_ONLINE       = "login.text.online"
+	LOGIN_TEXT_PENDING      = "login.text.pending"
+	LOGIN_TEXT_RECONNECTING = "login.text.reconnecting"
+	LOGIN_TEXT_REJECTED     = "login.text.rejected"
+	LOGIN_SAVE_LOCAL        = "login.save.local"
+	LOGIN_CONTINUE          = "login.continue"
+	LOGIN_LOGIN             = "login.login"
+	LOGIN_LOGOUT            = "login.logout"
+	LOGIN_USERNAME          = "login.username"
+	LOGIN_PASSWORD          = "login.password"
 
 	// Settings
 	//// System/Graphics
@@ -82,6 +91,22 @@ const (
 	SETTINGS_GAME_INPUTOFFSET  = "settings.game.inputoffset"
 	SETTINGS_GAME_LANESPEED    = "settings.game.lanespeed"
 	SETTINGS_GAME_EDGEPLAYAREA = "settings.game.edgeplayarea"
+	SETTINGS_GAME_JUDGEMENT    = "settings.game.judgement"
+
+	JUDGEMENT_LENIENT  = "judgement.lenient"
+	JUDGEMENT_STANDARD = "judgement.standard"
+	JUDGEMENT_STRICT   = "judgement.strict"
+	JUDGEMENT_CUSTOM   = "judgement.custom"
+
+	//// Modifiers
+	SETTINGS_MODS = "settings.mods"
+
+	MOD_MIRROR   = "mod.mirror"
+	MOD_FLIP     = "mod.flip"
+	MOD_SHUFFLE  = "mod.shuffle"
+	MOD_SUDDEN   = "mod.sudden"
+	MOD_HIDDEN   = "mod.hidden"
+	MOD_ALL_TAPS = "mod.alltaps"
 
 	//// Audio
 	SETTINGS_AUDIO                   = "settings.audio"
@@ -97,6 +122,10 @@ const (
 	SETTINGS_ACCESS_NOLANEEFFECT = "settings.access.nolaneeffect"
 	SETTINGS_ACCESS_MIRROR       = "settings.access.mirror"
 
+	//// Online
+	SETTINGS_ONLINE        = "settings.online"
+	SETTINGS_ONLINE_SERVER = "settings.online.server"
+
 	KEY_CONFIG_DEFAULT      = "keyconfig.default"
 	KEY_CONFIG_DEFAULT_DESC = "keyconfig.default.desc"
 	KEY_CONFIG_REDUCED      = "keyconfig.reduced"
@@ -116,6 +145,7 @@ const (
 	//// Play
 	STATE_PLAY_RESTART = "state.play.restart"
 	STATE_PLAY_PAUSE   = "state.play.pause"
+	STATE_PLAY_REPLAY  = "state.play.replay"
 
 	// Themes
 	THEME_STANDARD   = "theme.standard"
@@ -142,6 +172,7 @@ const (
 	ERROR_REGISTER_FAIL       = "error.register.fail"
 	ERROR_LOGIN_REGISTER_FAIL = "error.login.register.fail"
 	ERROR_LOGIN_FAILED        = "error.login.failed"
+	ERROR_PROFILE_NAME        = "error.profile.name"
 
 	// Etc
 	UNKNOWN = "unknown"
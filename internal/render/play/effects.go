@@ -33,7 +33,7 @@ func (r *Play) addHitEffects(screen *ebiten.Image) {
 		// This makes them shoot down the lane much faster
 		progress := types.GetTrackProgress(hitTime, now, -speed/8)
 		if progress > 0 && progress <= 1 {
-			if !hit.Note.IsHoldNote() {
+			if !drawnAsHold(hit.Note) {
 				r.addHitEffectShader(screen, hit.Note, float32(progress))
 			}
 		} else {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/liqmix/slaptrax/internal/display"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/ui"
 	"github.com/liqmix/slaptrax/internal/user"
@@ -78,9 +79,9 @@ func getPointPosition(point *ui.Point, progress float32) (float32, float32) {
 }
 
 // GetNotePath creates a note path for effects (simplified version)
-func GetNotePath(track types.TrackName, note *types.Note, isEffect bool) *ui.CachedPath {
+func GetNotePath(track types.TrackName, note *types.Note, mods judge.Mods, isEffect bool) *ui.CachedPath {
 	progress := float32(note.Progress)
-	alpha := GetNoteFadeAlpha(progress)
+	alpha := GetModifiedNoteFadeAlpha(progress, mods)
 	if isEffect {
 		alpha = uint8(200 * progress)
 	}
@@ -2,6 +2,8 @@ package play
 
 import (
 	"github.com/liqmix/slaptrax/internal/display"
+	"github.com/liqmix/slaptrax/internal/judge"
+	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/ui"
 )
 
@@ -42,6 +44,16 @@ func GetNoteFadeAlpha(progress float32) uint8 {
 	return GetFadeAlpha(progress, noteMaxAlpha)
 }
 
+// GetModifiedNoteFadeAlpha is GetNoteFadeAlpha with the sudden and hidden modifiers layered on
+func GetModifiedNoteFadeAlpha(progress float32, mods judge.Mods) uint8 {
+	return uint8(float32(GetNoteFadeAlpha(progress)) * GetModifierAlpha(progress, mods))
+}
+
+// GetModifierAlpha returns how visible the sudden and hidden modifiers leave a note, from 0 to 1
+func GetModifierAlpha(progress float32, mods judge.Mods) float32 {
+	return types.ModifierAlpha(mods, SmoothProgress(float64(progress)))
+}
+
 func notePts(length float64) [][]*ui.Point {
 	centerLength := length * centerNoteLengthRatio
 
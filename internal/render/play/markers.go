@@ -1,6 +1,8 @@
 package play
 
 import (
+	"math"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/liqmix/slaptrax/internal/types"
@@ -9,23 +11,26 @@ import (
 
 func (r *Play) drawMeasureMarkers(screen *ebiten.Image) {
 	currentTime := r.state.CurrentTime()
-	beatInterval := r.state.Song.GetBeatInterval()
-	measureInterval := beatInterval * 4
+	tempo := r.state.Chart.Tempo
+	currentBeat := math.Floor(tempo.BeatAt(currentTime))
+	currentMeasure := math.Floor(currentBeat/4) * 4
+	scroll := r.state.Chart.Scroll
+	currentPosition := scroll.PositionAt(currentTime)
 	color := types.Gray
 
 	// Draw beat markers
-	for i := int64(0); i < 8; i++ {
-		beatTime := ((currentTime / beatInterval) + i) * beatInterval
+	for i := 0; i < 8; i++ {
+		beatTime := tempo.TimeAt(currentBeat + float64(i))
 		color.A = beatMarkerAlpha
-		progress := types.GetTrackProgress(beatTime, currentTime, r.state.GetTravelTime())
+		progress := types.GetScrollProgress(scroll.PositionAt(beatTime), currentPosition, r.state.GetTravelTime())
 		drawMarker(screen, progress, measureMarkerPoints, color)
 	}
 
 	// Draw measure markers
-	for i := int64(0); i < 2; i++ {
-		measureTime := ((currentTime / measureInterval) + i) * measureInterval
+	for i := 0; i < 2; i++ {
+		measureTime := tempo.TimeAt(currentMeasure + float64(i*4))
 		color.A = beatMarkerAlpha * 2
-		progress := types.GetTrackProgress(measureTime, currentTime, r.state.GetTravelTime())
+		progress := types.GetScrollProgress(scroll.PositionAt(measureTime), currentPosition, r.state.GetTravelTime())
 		drawMarker(screen, progress, measureMarkerPoints, color)
 	}
 }
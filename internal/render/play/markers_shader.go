@@ -1,6 +1,8 @@
 package play
 
 import (
+	"math"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/render/shaders"
 	"github.com/liqmix/slaptrax/internal/types"
@@ -14,13 +16,16 @@ func (r *Play) drawMeasureMarkersShader(screen *ebiten.Image) {
 	}
 	
 	currentTime := r.state.CurrentTime()
-	beatInterval := r.state.Song.GetBeatInterval()
-	measureInterval := beatInterval * 4
+	tempo := r.state.Chart.Tempo
+	currentBeat := math.Floor(tempo.BeatAt(currentTime))
+	currentMeasure := math.Floor(currentBeat/4) * 4
+	scroll := r.state.Chart.Scroll
+	currentPosition := scroll.PositionAt(currentTime)
 	
 	// Draw beat markers
-	for i := int64(0); i < 8; i++ {
-		beatTime := ((currentTime / beatInterval) + i) * beatInterval
-		rawProgress := types.GetTrackProgress(beatTime, currentTime, r.state.GetTravelTime())
+	for i := 0; i < 8; i++ {
+		beatTime := tempo.TimeAt(currentBeat + float64(i))
+		rawProgress := types.GetScrollProgress(scroll.PositionAt(beatTime), currentPosition, r.state.GetTravelTime())
 		
 		if rawProgress < 0 || rawProgress > 1 {
 			continue
@@ -34,9 +39,9 @@ func (r *Play) drawMeasureMarkersShader(screen *ebiten.Image) {
 	}
 
 	// Draw measure markers
-	for i := int64(0); i < 2; i++ {
-		measureTime := ((currentTime / measureInterval) + i) * measureInterval
-		rawProgress := types.GetTrackProgress(measureTime, currentTime, r.state.GetTravelTime())
+	for i := 0; i < 2; i++ {
+		measureTime := tempo.TimeAt(currentMeasure + float64(i*4))
+		rawProgress := types.GetScrollProgress(scroll.PositionAt(measureTime), currentPosition, r.state.GetTravelTime())
 		
 		if rawProgress < 0 || rawProgress > 1 {
 			continue
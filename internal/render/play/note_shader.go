@@ -6,6 +6,7 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/render/shaders"
 	"github.com/liqmix/slaptrax/internal/types"
+	"github.com/liqmix/slaptrax/internal/user"
 )
 
 // addNotePathShader adds note paths using shader rendering
@@ -29,18 +30,20 @@ func (r *Play) addNotePathShader(track *types.Track, screen *ebiten.Image) {
 	})
 	
 	// Render each note using shaders in depth order
+	mods := r.state.Modifiers()
 	for _, note := range sortedNotes {
 		// Skip rendering hold notes that are past their end time
-		if note.IsHoldNote() && note.ReleaseProgress >= 1.0 {
+		if drawnAsHold(note) && note.ReleaseProgress >= 1.0 {
 			continue
 		}
 		
-		if note.IsHoldNote() {
-			// Render hold note using hold shader
-			shaders.Renderer.RenderHoldNote(screen, track.Name, note, trackPoints, &playCenterPoint)
+		if drawnAsHold(note) {
+			// Render hold note using hold shader, shown while either end is visible
+			alpha := max(GetModifierAlpha(float32(note.Progress), mods), GetModifierAlpha(float32(note.ReleaseProgress), mods))
+			shaders.Renderer.RenderHoldNote(screen, track.Name, note, trackPoints, &playCenterPoint, alpha)
 		} else {
 			// Render regular note using note shader
-			shaders.Renderer.RenderNote(screen, track.Name, note, trackPoints, &playCenterPoint)
+			shaders.Renderer.RenderNote(screen, track.Name, note, trackPoints, &playCenterPoint, GetModifierAlpha(float32(note.Progress), mods))
 		}
 	}
 }
@@ -56,4 +59,10 @@ func EnableShaderRendering() {
 // DisableShaderRendering disables shader-based note rendering
 func DisableShaderRendering() {
 	ShaderRenderingEnabled = false
-}
\ No newline at end of file
+}
+
+// drawnAsHold reports whether a note is drawn as a hold,
+// disabling hold notes draws them as taps but still judges them as holds
+func drawnAsHold(note *types.Note) bool {
+	return note.IsHoldNote() && !user.S().DisableHoldNotes
+}
@@ -2,6 +2,7 @@ package shaders
 
 import (
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/ui"
 	"github.com/liqmix/slaptrax/internal/user"
@@ -130,8 +131,8 @@ func (sr *ShaderRenderer) createHoldNoteBoundedGeometry(trackPoints []*ui.Point,
 	}
 }
 
-// RenderNote renders a single note using shaders
-func (sr *ShaderRenderer) RenderNote(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point) {
+// RenderNote renders a single note using shaders, faded by alpha
+func (sr *ShaderRenderer) RenderNote(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point, alpha float32) {
 	if Manager == nil {
 		return
 	}
@@ -145,6 +146,7 @@ func (sr *ShaderRenderer) RenderNote(img *ebiten.Image, track types.TrackName, n
 	if uniforms == nil {
 		return
 	}
+	uniforms.Fade(alpha)
 	
 	// Create bounded geometry for this specific note
 	vertices := sr.createBoundedGeometry(trackPoints, centerPoint, uniforms.Progress)
@@ -178,17 +180,17 @@ func (sr *ShaderRenderer) RenderNote(img *ebiten.Image, track types.TrackName, n
 }
 
 // RenderHoldNote renders a hold note using shaders (now renders tail and head separately)
-func (sr *ShaderRenderer) RenderHoldNote(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point) {
+func (sr *ShaderRenderer) RenderHoldNote(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point, alpha float32) {
 	if Manager == nil {
 		return
 	}
 	
 	// In 3D mode, render tail and head separately
 	if user.S() != nil && user.S().Use3DNotes {
-		sr.RenderHoldNoteTail(img, track, note, trackPoints, centerPoint)
+		sr.RenderHoldNoteTail(img, track, note, trackPoints, centerPoint, alpha)
 		// Only render head when hold is not active (not hit yet or already released)
 		if !(note.WasHit() && !note.WasReleased()) {
-			sr.RenderHoldNoteHead(img, track, note, trackPoints, centerPoint)
+			sr.RenderHoldNoteHead(img, track, note, trackPoints, centerPoint, alpha)
 		}
 	} else {
 		// In 2D mode, use the original hold note shader
@@ -201,6 +203,7 @@ func (sr *ShaderRenderer) RenderHoldNote(img *ebiten.Image, track types.TrackNam
 		if uniforms == nil {
 			return
 		}
+		uniforms.Fade(alpha)
 		
 		// Create bounded geometry for this hold note spanning from start to end progress
 		vertices := sr.createHoldNoteBoundedGeometry(trackPoints, centerPoint, uniforms.HoldStartProgress, uniforms.HoldEndProgress)
@@ -241,7 +244,7 @@ func (sr *ShaderRenderer) RenderHoldNote(img *ebiten.Image, track types.TrackNam
 }
 
 // RenderHoldNoteTail renders the tail portion of a hold note using the tail shader
-func (sr *ShaderRenderer) RenderHoldNoteTail(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point) {
+func (sr *ShaderRenderer) RenderHoldNoteTail(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point, alpha float32) {
 	tailShader := Manager.GetHoldTailShader()
 	if tailShader == nil {
 		return
@@ -251,6 +254,7 @@ func (sr *ShaderRenderer) RenderHoldNoteTail(img *ebiten.Image, track types.Trac
 	if uniforms == nil {
 		return
 	}
+	uniforms.Fade(alpha)
 	
 	// Create bounded geometry for the tail (full hold length)
 	vertices := sr.createHoldNoteBoundedGeometry(trackPoints, centerPoint, uniforms.HoldStartProgress, uniforms.HoldEndProgress)
@@ -290,7 +294,7 @@ func (sr *ShaderRenderer) RenderHoldNoteTail(img *ebiten.Image, track types.Trac
 }
 
 // RenderHoldNoteHead renders the head portion of a hold note using the regular note shader
-func (sr *ShaderRenderer) RenderHoldNoteHead(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point) {
+func (sr *ShaderRenderer) RenderHoldNoteHead(img *ebiten.Image, track types.TrackName, note *types.Note, trackPoints []*ui.Point, centerPoint *ui.Point, alpha float32) {
 	noteShader := Manager.GetNoteShader()
 	if noteShader == nil {
 		return
@@ -305,8 +309,8 @@ func (sr *ShaderRenderer) RenderHoldNoteHead(img *ebiten.Image, track types.Trac
 	
 	// Create a temporary note at the hold end position for the head
 	headNote := &types.Note{
+		Note:            judge.Note{Target: note.TargetRelease}, // Use release target for head position
 		TrackName:       note.TrackName,
-		Target:          note.TargetRelease, // Use release target for head position
 		Progress:        note.ReleaseProgress, // Use release progress for head position
 		Solo:            note.Solo,
 	}
@@ -326,6 +330,7 @@ func (sr *ShaderRenderer) RenderHoldNoteHead(img *ebiten.Image, track types.Trac
 		uniforms.ColorA = holdUniforms.ColorA
 		uniforms.Solo = holdUniforms.Solo
 	}
+	uniforms.Fade(alpha)
 	
 	// Create bounded geometry for the head note
 	vertices := sr.createBoundedGeometry(trackPoints, centerPoint, uniforms.Progress)
@@ -141,7 +141,7 @@ func CreateHoldNoteUniforms(track types.TrackName, note *types.Note, trackPoints
 	}
 	
 	holdUniforms.IsActive = 0.0
-	if note.IsActive {
+	if note.Active {
 		holdUniforms.IsActive = 1.0
 	}
 	
@@ -187,6 +187,14 @@ func CreateHitEffectUniforms(track types.TrackName, note *types.Note, trackPoint
 	return hitUniforms
 }
 
+// Fade scales the color of the note by alpha, the shaders take premultiplied colors
+func (u *NoteUniforms) Fade(alpha float32) {
+	u.ColorR *= alpha
+	u.ColorG *= alpha
+	u.ColorB *= alpha
+	u.ColorA *= alpha
+}
+
 // ToSlice converts NoteUniforms to float32 slice for shader
 func (u *NoteUniforms) ToSlice() []float32 {
 	return []float32{
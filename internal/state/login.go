@@ -60,12 +60,12 @@ func (s *LoginState) handleLogin(username, password string) {
 	// Attempt login
 	err := external.Login(username, password, true)
 	if err != nil {
-		// Check if user doesn't exist (404)
-		if strings.Contains(err.Error(), fmt.Sprintf("%d", http.StatusNotFound)) {
-			// Try to register
+		// Unknown users are refused like a wrong password (401), so try registering
+		if strings.Contains(err.Error(), fmt.Sprintf("%d", http.StatusUnauthorized)) {
 			err = external.Register(username, password)
 			if err != nil {
-				s.modal.SetError(l.String(l.ERROR_REGISTER_FAIL) + "\n" + err.Error())
+				// Most likely the username is taken and the password was wrong
+				s.modal.SetError(l.String(l.ERROR_LOGIN_FAILED) + "\n" + err.Error())
 				s.loading = false
 				return
 			}
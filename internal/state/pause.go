@@ -3,6 +3,7 @@ package state
 import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/audio"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/l"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/ui"
@@ -11,6 +12,7 @@ import (
 type PauseArgs struct {
 	Song       *types.Song
 	Difficulty types.Difficulty
+	Replay     *judge.Replay // Restarts watching the replay when set
 	Cb         func()
 }
 
@@ -67,6 +69,7 @@ func NewPauseState(args *PauseArgs) *Pause {
 		p.SetNextState(types.GameStatePlay, &PlayArgs{
 			Song:       args.Song,
 			Difficulty: args.Difficulty,
+			Replay:     args.Replay,
 		})
 	})
 	group.Add(e)
@@ -1,11 +1,13 @@
 package state
 
 import (
+	"math"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/audio"
 	"github.com/liqmix/slaptrax/internal/input"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/user"
 )
@@ -13,6 +15,8 @@ import (
 type PlayArgs struct {
 	Song       *types.Song
 	Difficulty types.Difficulty
+	Replay     *judge.Replay   // Watched instead of played when set
+	Autoplay   *judge.Humanize // Watched being played by autoplay when set
 }
 
 type Play struct {
@@ -21,11 +25,18 @@ type Play struct {
 	Difficulty   types.Difficulty
 	Tracks       []*types.Track
 	Score        *types.Score
+	Simulation   *types.Simulation
 	Chart        *types.Chart
 	EventContext *types.EventContext
 	startTime    time.Time
 	elapsedTime  int64
 	countTicks   []int64
+
+	// Latest time judged, resuming from a pause rewinds the song to before it
+	judgedUntil int64
+
+	// Feeds the recorded input when watching a replay
+	playback *judge.Playback
 }
 
 const travelTime float64 = 5000
@@ -38,12 +49,10 @@ func NewPlayState(args *PlayArgs) *Play {
 		panic("No chart for difficulty")
 	}
 
-	tracks := chart.Tracks
-
 	// Get the song audio ready
 	audio.StopAll()
 	audio.InitSong(song)
-	for _, track := range tracks {
+	for _, track := range chart.Tracks {
 		track.Reset()
 	}
 
@@ -51,34 +60,91 @@ func NewPlayState(args *PlayArgs) *Play {
 	p := &Play{
 		Song:        song,
 		Difficulty:  difficulty,
-		Tracks:      tracks,
 		Chart:       chart,
 		Score:       types.NewScore(song, difficulty),
 		elapsedTime: 0,
+		judgedUntil: math.MinInt64,
 		startTime:   time.Now(),
-		countTicks:  song.GetCountdownTicks(false),
+		countTicks:  chart.GetCountdownTicks(0, false),
 		EventContext: &types.EventContext{
 			Song:  song,
 			Chart: chart,
 			// TODO: Add system references when implementing visual effects
 		},
 	}
+	opts := judge.Options{
+		InputOffset: user.S().InputOffset,
+		Profile:     user.S().JudgementProfile(),
+		TravelTime:  p.GetTravelTime(),
+		Mods:        user.S().Modifiers,
+	}
+	if opts.Mods.Has(judge.ModShuffle) {
+		opts.Seed = time.Now().UnixNano()
+	}
+	replay := args.Replay
+	if replay != nil {
+		opts = replay.Options
+	}
+
+	// Notes are played on the lanes the modifiers move them to
+	tracks := types.ArrangeTracks(chart.Tracks, opts)
+	p.Tracks = tracks
+	if replay == nil && args.Autoplay != nil {
+		replay = types.Autoplay(tracks, opts, *args.Autoplay)
+		p.setReplaySettings(replay)
+	}
+
+	if replay != nil {
+		// Judged with the settings it was played with
+		p.Score.Replay = replay
+		p.playback = judge.NewPlayback(replay)
+	} else {
+		p.Score.Replay = judge.NewReplay(opts)
+		p.setReplaySettings(p.Score.Replay)
+	}
+	sim, err := types.NewSimulation(tracks, p.Score, p.Score.Replay.Options)
+	if err != nil {
+		panic(err)
+	}
+	p.Simulation = sim
 	p.SetAction(input.ActionBack, p.pause)
 	p.SetNotNavigable()
 	return p
 }
 
 func (p *Play) GetTravelTime() int64 {
+	if p.IsPlayback() && p.Score.Replay.Options.TravelTime > 0 {
+		return p.Score.Replay.Options.TravelTime
+	}
 	return int64(travelTime / user.S().LaneSpeed)
 }
 
+// Modifiers returns the modifiers the play is judged with
+func (p *Play) Modifiers() judge.Mods {
+	return p.Score.Replay.Options.Mods
+}
+
+// setReplaySettings records the chart and current settings a replay is played with
+func (p *Play) setReplaySettings(replay *judge.Replay) {
+	replay.Song = p.Song.Hash
+	replay.Difficulty = int(p.Difficulty)
+	replay.AudioOffset = user.S().AudioOffset
+	replay.LaneSpeed = user.S().LaneSpeed
+}
+
+// IsPlayback reports if a replay is being watched
+func (p *Play) IsPlayback() bool {
+	return p.playback != nil
+}
+
 func (p *Play) pause() {
 	p.SetNextState(types.GameStatePause,
 		&PauseArgs{
 			Song:       p.Song,
 			Difficulty: p.Difficulty,
+			Replay:     p.replay(),
 			Cb: func() {
-				p.countTicks = p.Song.GetCountdownTicks(true)
+				p.countTicks = p.Chart.GetCountdownTicks(p.elapsedTime, true)
 				p.startTime = time.Now()
 				if p.elapsedTime >= user.S().AudioOffset {
 					audio.SetSongPositionMS(int(p.elapsedTime - p.getGracePeriod()))
@@ -88,19 +154,34 @@ func (p *Play) pause() {
 		})
 }
 
+// replay returns the replay being watched, nil when playing
+func (p *Play) replay() *judge.Replay {
+	if !p.IsPlayback() {
+		return nil
+	}
+	return p.Score.Replay
+}
+
 func (p *Play) CurrentTime() int64 {
 	return p.elapsedTime
 }
 
+// MaxTrackTime is the latest note target that has reached the track.
+// Notes enter the track no later than the travel time before their target,
+// and earlier when the scroll slows down or stops.
 func (p *Play) MaxTrackTime() int64 {
-	return p.elapsedTime + p.GetTravelTime()
+	travelTime := p.GetTravelTime()
+	scroll := p.Chart.Scroll
+	visible := scroll.LatestTimeAt(scroll.PositionAt(p.elapsedTime) + float64(travelTime))
+	return max(visible, p.elapsedTime+travelTime)
 }
 
 func (p *Play) getGracePeriod() int64 {
-	if currentPos := audio.CurrentSongPositionMS(); currentPos <= 0 {
-		return p.Song.GetBeatInterval() * 8
+	currentPos := audio.CurrentSongPositionMS()
+	if currentPos <= 0 {
+		return p.Chart.GetBeatInterval(0) * 8
 	}
-	return p.Song.GetBeatInterval() * 4
+	return p.Chart.GetBeatInterval(currentPos) * 4
 }
 
 func (p *Play) inGracePeriod() bool {
@@ -137,7 +218,8 @@ func (p *Play) Update() error {
 			}
 			if !stillPlaying {
 				p.SetNextState(types.GameStateResult, &ResultStateArgs{
-					Score: p.Score,
+					Score:   p.Score,
+					Watched: p.IsPlayback(),
 				})
 			}
 		}
@@ -153,11 +235,24 @@ func (p *Play) Update() error {
 		}
 	}
 
+	// Judge the input recorded this frame, exactly as the replay will be.
+	// Time already judged isn't judged again after a pause, the replay leaves it out.
+	activeBefore := make([]bool, len(p.Tracks))
+	for i, track := range p.Tracks {
+		activeBefore[i] = track.Active
+	}
+	if p.elapsedTime >= p.judgedUntil {
+		for _, e := range p.getInput() {
+			p.Simulation.Input(e)
+		}
+		p.Simulation.Step(p.elapsedTime)
+		p.judgedUntil = p.elapsedTime
+	}
+
 	// Update the tracks
-	for _, track := range p.Tracks {
-		activeBefore := track.Active
+	for i, track := range p.Tracks {
 		track.Update(p.elapsedTime, p.GetTravelTime(), p.MaxTrackTime())
-		if !activeBefore && track.Active {
+		if !activeBefore[i] && track.Active {
 			audio.PlayTrackSFX(track.Name)
 		}
 	}
@@ -165,4 +260,24 @@ func (p *Play) Update() error {
 	return nil
 }
 
+// getInput returns this frame's presses and releases, from the replay when watching one
+func (p *Play) getInput() []judge.InputEvent {
+	if p.IsPlayback() {
+		return p.playback.Next(p.elapsedTime)
+	}
+
+	// Input that can't be judged is left out, the server rejects replays with it
+	start, end := p.Simulation.Window()
+	if p.elapsedTime < start || p.elapsedTime > end || len(p.Score.Replay.Events)+2*len(p.Tracks) > p.Simulation.EventLimit() {
+		return nil
+	}
+
+	events := make([]judge.InputEvent, 0)
+	for _, track := range p.Tracks {
+		action := track.Name.Action()
+		events = append(events, p.Score.Replay.Record(p.elapsedTime, track.Name.SchemaName(), input.JustActioned(action), input.IsActioned(action))...)
+	}
+	return events
+}
+
 func (p *Play) Draw(screen *ebiten.Image, opts *ebiten.DrawImageOptions) {}
@@ -0,0 +1,132 @@
+package state
+
+import (
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/liqmix/slaptrax/internal/external"
+	"github.com/liqmix/slaptrax/internal/input"
+	"github.com/liqmix/slaptrax/internal/l"
+	"github.com/liqmix/slaptrax/internal/logger"
+	"github.com/liqmix/slaptrax/internal/types"
+	"github.com/liqmix/slaptrax/internal/ui"
+	"github.com/liqmix/slaptrax/internal/user"
+)
+
+// ProfileState picks the player profile to play as, or creates a new one
+type ProfileState struct {
+	types.BaseGameState
+	panel     *ui.UIGroup
+	title     *ui.Element
+	errorText string
+}
+
+func NewProfileState() *ProfileState {
+	state := &ProfileState{}
+
+	// Enable text input for the name of a new player
+	input.SetAllowTextInput(true)
+
+	panel := ui.NewUIGroup()
+	panel.SetPaneled(true)
+	panel.SetCenter(ui.Point{X: 0.5, Y: 0.5})
+	panel.SetSize(ui.Point{X: 0.4, Y: 0.8})
+
+	title := ui.NewElement()
+	title.SetCenter(ui.Point{X: 0.5, Y: 0.17})
+	title.SetText(l.String(l.STATE_PROFILE))
+	title.SetTextScale(2)
+	state.title = title
+
+	buttonSize := ui.Point{X: 0.2, Y: 0.05}
+
+	// Existing players, squeezed together when there are many
+	profiles := user.Profiles()
+	step := 0.06
+	if len(profiles) > 0 {
+		step = min(step, 0.37/float64(len(profiles)))
+	}
+	center := ui.Point{X: 0.5, Y: 0.25}
+	for _, profile := range profiles {
+		b := ui.NewElement()
+		b.SetCenter(center)
+		b.SetSize(buttonSize)
+		b.SetText(profile)
+		b.SetTextScale(1.5)
+		if profile == user.Profile() {
+			b.SetTextColor(ui.CenterTrackColor())
+		}
+		b.SetTrigger(func() {
+			state.switchTo(profile)
+		})
+		panel.Add(b)
+		center.Y += step
+	}
+
+	// New player
+	name := ui.NewTextInput(l.String(l.PROFILE_NEW))
+	name.SetPaneled(true)
+	name.SetMaxLength(external.MaxPlayerNameLen)
+	name.SetSize(ui.Point{X: 0.2, Y: 0.08})
+	name.SetTextScale(1.5)
+	name.SetCenter(ui.Point{X: 0.5, Y: 0.7})
+	panel.Add(name)
+
+	create := ui.NewElement()
+	create.SetCenter(ui.Point{X: 0.5, Y: 0.79})
+	create.SetSize(buttonSize)
+	create.SetText(l.String(l.OK))
+	create.SetTextColor(ui.CenterTrackColor())
+	create.SetTrigger(func() {
+		newName := strings.TrimSpace(name.GetText())
+		if !external.ValidPlayerName(newName) {
+			state.errorText = l.String(l.ERROR_PROFILE_NAME)
+			return
+		}
+		state.switchTo(newName)
+	})
+	panel.Add(create)
+
+	back := ui.NewElement()
+	back.SetCenter(ui.Point{X: 0.5, Y: 0.86})
+	back.SetSize(buttonSize)
+	back.SetText(l.String(l.BACK))
+	back.SetTrigger(func() {
+		input.SetAllowTextInput(false)
+		state.SetNextState(types.GameStateBack, nil)
+	})
+	panel.Add(back)
+
+	state.panel = panel
+	return state
+}
+
+// switchTo plays as the named player, creating them if they don't exist yet
+func (s *ProfileState) switchTo(name string) {
+	if err := user.SwitchProfile(name); err != nil {
+		logger.Error("Failed to switch player profile: %v", err)
+		s.errorText = err.Error()
+		return
+	}
+
+	// The title is rebuilt for the new player
+	input.SetAllowTextInput(false)
+	s.SetNextState(types.GameStateTitle, nil)
+}
+
+func (s *ProfileState) Update() error {
+	s.BaseGameState.Update()
+	s.panel.Update()
+	return nil
+}
+
+func (s *ProfileState) Draw(screen *ebiten.Image, opts *ebiten.DrawImageOptions) {
+	s.panel.Draw(screen, opts)
+	s.title.Draw(screen, opts)
+
+	if s.errorText != "" {
+		textOpts := ui.GetDefaultTextOptions()
+		textOpts.Color = types.Red.C()
+		ui.DrawTextAt(screen, s.errorText, &ui.Point{X: 0.5, Y: 0.63}, textOpts, opts)
+	}
+}
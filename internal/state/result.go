@@ -17,13 +17,15 @@ import (
 )
 
 type ResultStateArgs struct {
-	Score *types.Score
+	Score   *types.Score
+	Watched bool // The score is from watching a replay, so it isn't recorded
 }
 
 type Result struct {
 	types.BaseGameState
 
 	previousScore *external.Score
+	previousBest  *external.PlayRecord
 	score         *types.Score
 	rating        *ui.Element
 	group         *ui.UIGroup
@@ -48,34 +50,16 @@ func NewResultState(args *ResultStateArgs) *Result {
 		})
 	}
 	r.bmager = bmager
-	if external.HasConnection() {
-		go func() {
-			// Get previous score
-			previousScore, err := external.GetScore(score.Song.Hash, int(score.Difficulty))
-			if err == nil {
-				r.previousScore = previousScore
-			}
 
-			// Send score
-			err = external.AddScore(&external.Score{
-				SongHash:   score.Song.Hash,
-				Difficulty: int(score.Difficulty),
-				Score:      score.TotalScore,
-				MaxCombo:   score.MaxCombo,
-				Accuracy:   score.GetAccuracy(),
-				PlayedAt:   time.Now(),
-			})
-			if err != nil {
-				logger.Error(err.Error())
-			}
-		}()
+	if !args.Watched {
+		r.record()
 	}
 
 	g := ui.NewUIGroup()
 	g.SetHorizontal()
 
 	//// Buttons
-	position := ui.Point{X: 0.45, Y: 0.85}
+	position := ui.Point{X: 0.4, Y: 0.85}
 	e := ui.NewElement()
 	e.SetCenter(position)
 	e.SetText(l.String(l.CONTINUE))
@@ -93,6 +77,15 @@ func NewResultState(args *ResultStateArgs) *Result {
 	})
 	g.Add(e)
 
+	position.X += 0.1
+	e = ui.NewElement()
+	e.SetCenter(position)
+	e.SetText(l.String(l.STATE_PLAY_REPLAY))
+	e.SetTrigger(func() {
+		r.SetNextState(types.GameStatePlay, &PlayArgs{Song: r.score.Song, Difficulty: r.score.Difficulty, Replay: r.score.Replay})
+	})
+	g.Add(e)
+
 	img := display.NewRenderImage()
 	center := ui.Point{X: 0.5, Y: 0.25}
 	textOpts := ui.GetDefaultTextOptions()
@@ -154,10 +147,88 @@ func NewResultState(args *ResultStateArgs) *Result {
 	ui.DrawTextAt(img, fmt.Sprintf("EARLY\n%d", score.Early), &ui.Point{X: 0.33, Y: detailsStart}, leftTextOpts, nil)
 	rightTextOpts.Color = types.Purple.C()
 	ui.DrawTextAt(img, fmt.Sprintf("LATE\n%d", score.Late), &ui.Point{X: 0.66, Y: detailsStart}, rightTextOpts, nil)
+
+	textOpts.Color = types.Gray.C()
+	judgement := fmt.Sprintf("%s: %s", l.String(l.SETTINGS_GAME_JUDGEMENT), ui.JudgementText(score.Replay.Options.JudgementProfile()))
+	ui.DrawTextAt(img, judgement, &ui.Point{X: 0.5, Y: 0.77}, textOpts, nil)
+	if mods := score.Replay.Options.Mods; mods != 0 {
+		modifiers := fmt.Sprintf("%s: %s", l.String(l.SETTINGS_MODS), ui.ModsText(mods))
+		ui.DrawTextAt(img, modifiers, &ui.Point{X: 0.5, Y: 0.8}, textOpts, nil)
+	}
 	r.text = img
 	return r
 }
 
+// record keeps the play in the local history and queues it for the server
+func (r *Result) record() {
+	score := r.score
+
+	// Record the play locally, whether or not it reaches the server
+	profile := score.Replay.Options.JudgementProfile()
+	judgement := profile.ID()
+	mods := score.Replay.Options.Mods
+	r.previousBest = external.GetBestPlay(score.Song.Hash, int(score.Difficulty), judgement)
+	replayPath, err := external.SaveReplay(score.Replay)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	// Ties the local play to its submission, empty leaves it to AddScore
+	playID, err := external.NewPlayID()
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	err = external.AddPlay(&external.PlayRecord{
+		SongHash:         score.Song.Hash,
+		Difficulty:       int(score.Difficulty),
+		Score:            score.TotalScore,
+		Slap:             score.Slap,
+		Slip:             score.Slip,
+		Slop:             score.Slop,
+		Early:            score.Early,
+		Late:             score.Late,
+		MaxCombo:         score.MaxCombo,
+		HoldIntervals:    score.HoldIntervals,
+		HoldIntervalsHit: score.HoldIntervalsHit,
+		Judgement:        judgement,
+		Mods:             mods,
+		Replay:           replayPath,
+		PlayedAt:         time.Now(),
+		PlayID:           playID,
+	})
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	go func() {
+		// Get previous score
+		if external.HasConnection() {
+			previousScore, err := external.GetScore(score.Song.Hash, int(score.Difficulty), judgement)
+			if err == nil {
+				r.previousScore = previousScore
+			}
+		}
+
+		// Queue score, it's sent once there is a session
+		err := external.AddScore(&external.Score{
+			SongHash:   score.Song.Hash,
+			Difficulty: int(score.Difficulty),
+			Score:      score.TotalScore,
+			MaxCombo:   score.MaxCombo,
+			Accuracy:   score.GetAccuracy(),
+			PlayedAt:   time.Now(),
+			Judgement:  judgement,
+			Mods:       mods,
+			Unranked:   mods.Unranked() || !profile.Builtin(),
+			PlayID:     playID,
+			Replay:     score.Replay,
+		})
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}()
+}
+
 func (r *Result) Update() error {
 	if audio.IsSongPlaying() {
 		// hmm
@@ -179,11 +250,22 @@ func (r *Result) Draw(screen *ebiten.Image, opts *ebiten.DrawImageOptions) {
 	screen.DrawImage(r.text, nil)
 	r.group.Draw(screen, opts)
 	r.rating.Draw(screen, opts)
-	if r.previousScore != nil {
-		// Draw previous score
+	if best, ok := r.getPreviousBest(); ok {
+		// Draw previous best, local or from the server if it's higher
 		textOpts := ui.GetDefaultTextOptions()
 		textOpts.Scale = 1.0
 		textOpts.Color = types.Gray.C()
-		ui.DrawTextAt(screen, fmt.Sprintf("PREVIOUS SCORE: %d", r.previousScore.Score), &ui.Point{X: 0.5, Y: 0.49}, textOpts, nil)
+		ui.DrawTextAt(screen, fmt.Sprintf("%s: %d", l.String(l.PERSONAL_BEST), best), &ui.Point{X: 0.5, Y: 0.49}, textOpts, nil)
+	}
+}
+
+func (r *Result) getPreviousBest() (int, bool) {
+	best, ok := 0, false
+	if r.previousBest != nil {
+		best, ok = r.previousBest.Score, true
+	}
+	if r.previousScore != nil && r.previousScore.Score > best {
+		best, ok = r.previousScore.Score, true
 	}
+	return best, ok
 }
@@ -7,8 +7,11 @@ import (
 	"github.com/liqmix/slaptrax/internal/audio"
 	"github.com/liqmix/slaptrax/internal/cache"
 	"github.com/liqmix/slaptrax/internal/display"
+	"github.com/liqmix/slaptrax/internal/external"
 	"github.com/liqmix/slaptrax/internal/input"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/l"
+	"github.com/liqmix/slaptrax/internal/logger"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/ui"
 	"github.com/liqmix/slaptrax/internal/user"
@@ -48,6 +51,12 @@ func (s *Settings) Refresh() {
 	s.tabs.Add(l.String(l.SETTINGS_GAME), g)
 	tabCenter.X += tabOffset
 
+	// Modifiers Tab
+	g = ui.NewUIGroup()
+	s.createModifierOptions(g)
+	s.tabs.Add(l.String(l.SETTINGS_MODS), g)
+	tabCenter.X += tabOffset
+
 	// Graphics Tab
 	g = ui.NewUIGroup()
 	s.createGraphicsOptions(g)
@@ -65,6 +74,12 @@ func (s *Settings) Refresh() {
 	g.SetCenter(tabCenter)
 	s.createAccessOptions(g)
 	s.tabs.Add(l.String(l.SETTINGS_ACCESS), g)
+	tabCenter.X += tabOffset
+
+	// Online Tab
+	g = ui.NewUIGroup()
+	s.createOnlineOptions(g)
+	s.tabs.Add(l.String(l.SETTINGS_ONLINE), g)
 
 	s.tabs.SetCenter(
 		ui.Point{X: 0.5, Y: 0.15},
@@ -380,6 +395,34 @@ func (s *Settings) createGameplayOptions(group *ui.UIGroup) {
 	group.Add(b)
 	optionPos.Y += optionsOffset
 
+	// Judgement, the custom table is only offered once it's set in the settings file
+	judgements := make([]string, 0, len(judge.Profiles)+1)
+	for _, p := range judge.Profiles {
+		judgements = append(judgements, p.Name)
+	}
+	if custom := user.S().CustomJudgement; custom != nil && custom.Validate() == nil {
+		judgements = append(judgements, judge.CustomProfile)
+	}
+	currentJudgementIdx := 0
+	for i, name := range judgements {
+		if user.S().Judgement == name {
+			currentJudgementIdx = i
+			break
+		}
+	}
+	b = ui.NewValueElement()
+	b.SetCenter(optionPos)
+	b.SetLabel(l.String(l.SETTINGS_GAME_JUDGEMENT))
+	b.SetGetValueText(func() string {
+		return ui.JudgementText(user.S().JudgementProfile())
+	})
+	b.SetTrigger(func() {
+		currentJudgementIdx = (currentJudgementIdx + 1) % len(judgements)
+		user.S().Judgement = judgements[currentJudgementIdx]
+	})
+	group.Add(b)
+	optionPos.Y += optionsOffset
+
 	// Edge play area
 	b = ui.NewValueElement()
 	b.SetCenter(optionPos)
@@ -435,6 +478,31 @@ func (s *Settings) createGameplayOptions(group *ui.UIGroup) {
 	group.Add(inputOffset)
 }
 
+func (s *Settings) createModifierOptions(group *ui.UIGroup) {
+	optionPos := ui.Point{
+		X: optionsStart.X,
+		Y: optionsStart.Y,
+	}
+
+	for _, modifier := range judge.Modifiers {
+		mod := modifier.Mod
+		b := ui.NewValueElement()
+		b.SetCenter(optionPos)
+		b.SetLabel(ui.ModifierText(mod))
+		b.SetGetValueText(func() string {
+			if user.S().Modifiers.Has(mod) {
+				return l.String(l.ON)
+			}
+			return l.String(l.OFF)
+		})
+		b.SetTrigger(func() {
+			user.S().Modifiers ^= mod
+		})
+		group.Add(b)
+		optionPos.Y += optionsOffset
+	}
+}
+
 func (s *Settings) createAccessOptions(group *ui.UIGroup) {
 	optionPos := ui.Point{
 		X: optionsStart.X,
@@ -506,6 +574,53 @@ func (s *Settings) createAccessOptions(group *ui.UIGroup) {
 	optionPos.Y += optionsOffset
 }
 
+func (s *Settings) createOnlineOptions(group *ui.UIGroup) {
+	optionPos := ui.Point{
+		X: optionsStart.X,
+		Y: optionsStart.Y,
+	}
+
+	// Server profiles are added in settings.json, only switching happens here
+	profiles := user.S().GetServerProfiles()
+	currentProfileIdx := -1
+	for i, profile := range profiles {
+		if profile.Name == external.GetServerProfile().Name {
+			currentProfileIdx = i
+			break
+		}
+	}
+	switching := false
+
+	b := ui.NewValueElement()
+	b.SetCenter(optionPos)
+	b.SetLabel(l.String(l.SETTINGS_ONLINE_SERVER))
+	b.SetGetValueText(func() string {
+		if switching {
+			return l.String(l.LOADING)
+		}
+		return external.GetServerProfile().Name
+	})
+	b.SetTrigger(func() {
+		if switching || (len(profiles) < 2 && currentProfileIdx == 0) {
+			return
+		}
+		currentProfileIdx = (currentProfileIdx + 1) % len(profiles)
+		switching = true
+		b.Refresh()
+
+		// Logging in to the other server goes over the network
+		go func() {
+			if err := external.SwitchServerProfile(profiles[currentProfileIdx].Name); err != nil {
+				logger.Error("Failed to switch server: %v", err)
+			}
+			switching = false
+			b.Refresh()
+		}()
+	})
+	group.Add(b)
+	optionPos.Y += optionsOffset
+}
+
 func (s *Settings) Update() error {
 	s.BaseGameState.Update()
 
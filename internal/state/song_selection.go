@@ -2,19 +2,45 @@ package state
 
 import (
 	"sort"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/assets"
 	"github.com/liqmix/slaptrax/internal/audio"
 	"github.com/liqmix/slaptrax/internal/input"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/l"
 	"github.com/liqmix/slaptrax/internal/logger"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/ui"
+	"github.com/liqmix/slaptrax/internal/user"
 )
 
 var lastIdx = 0
 
+// Autoplay plays the chart instead of the player, see SetAutoplay
+var autoplay struct {
+	enabled  bool
+	humanize judge.Humanize
+}
+
+// SetAutoplay sets whether charts start with autoplay and how humanized it plays.
+// It can be toggled on song selection after.
+func SetAutoplay(enabled bool, h judge.Humanize) {
+	autoplay.enabled = enabled
+	autoplay.humanize = h
+}
+
+// getAutoplay returns how autoplay should play, nil when it's off
+func getAutoplay() *judge.Humanize {
+	if !autoplay.enabled {
+		return nil
+	}
+	h := autoplay.humanize
+	h.Seed = time.Now().UnixNano()
+	return &h
+}
+
 type SongSelectionArgs struct {
 	Song *types.Song
 }
@@ -112,6 +138,7 @@ func NewSongSelectionState() *SongSelection {
 				s.SetNextState(types.GameStatePlay, &PlayArgs{
 					Song:       o.song,
 					Difficulty: o.difficulty,
+					Autoplay:   getAutoplay(),
 				})
 			})
 			songElements.Add(e)
@@ -140,6 +167,9 @@ func NewSongSelectionState() *SongSelection {
 
 func (s *SongSelection) Update() error {
 	s.BaseGameState.Update()
+	if input.JustActioned(input.ActionToggleAutoplay) {
+		autoplay.enabled = !autoplay.enabled
+	}
 
 	s.songList.Update()
 	idx := s.songList.GetIndex()
@@ -152,7 +182,7 @@ func (s *SongSelection) Update() error {
 		diff := s.options[optionIdx].difficulty
 		s.details.UpdateDetails(song, diff)
 		audio.PlaySongPreview(song)
-		s.leaderboard.FetchScores(song.Hash, int(diff), float64(song.BPM))
+		s.leaderboard.FetchScores(song.Hash, int(diff), song.GetChart(diff).Tempo)
 	}
 
 	s.details.Update()
@@ -164,4 +194,15 @@ func (s *SongSelection) Draw(screen *ebiten.Image, opts *ebiten.DrawImageOptions
 	s.songList.Draw(screen, opts)
 	s.details.Draw(screen, opts)
 	s.leaderboard.Draw(screen, opts)
+
+	if autoplay.enabled {
+		textOpts := ui.GetDefaultTextOptions()
+		textOpts.Color = types.Yellow.C()
+		ui.DrawTextAt(screen, l.String(l.AUTOPLAY), &ui.Point{X: 0.5, Y: 0.92}, textOpts, nil)
+	}
+	if mods := user.S().Modifiers; mods != 0 {
+		textOpts := ui.GetDefaultTextOptions()
+		textOpts.Color = types.Gray.C()
+		ui.DrawTextAt(screen, ui.ModsText(mods), &ui.Point{X: 0.5, Y: 0.95}, textOpts, nil)
+	}
 }
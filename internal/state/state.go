@@ -38,6 +38,7 @@ var FloatingStates = map[types.GameState]bool{
 	types.GameStateDifficultySelection: true,
 	types.GameStateModal:               true,
 	types.GameStateLogin:               true,
+	types.GameStateProfile:             true,
 	types.GameStateHowToPlay:           true,
 	types.GameStateKeyConfig:           true,
 }
@@ -64,6 +65,8 @@ func New(s types.GameState, arg interface{}) State {
 		state = NewResultState(arg.(*ResultStateArgs))
 	case types.GameStateLogin:
 		state = NewLoginState()
+	case types.GameStateProfile:
+		state = NewProfileState()
 	case types.GameStateModal:
 		state = NewModalState(arg.(*ModalStateArgs))
 	case types.GameStateHowToPlay:
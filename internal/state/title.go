@@ -1,6 +1,8 @@
 package state
 
 import (
+	"fmt"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/assets"
 	"github.com/liqmix/slaptrax/internal/audio"
@@ -230,6 +232,20 @@ func NewTitleState() *Title {
 		center.Y += offset
 	}
 
+	// Player profile, picked or created in its own state
+	profileText := func() string {
+		return fmt.Sprintf("%s: %s", l.String(l.PROFILE), user.Profile())
+	}
+	profile := ui.NewElement()
+	profile.SetCenter(center)
+	profile.SetSize(buttonSize)
+	profile.SetText(profileText())
+	profile.SetTrigger(func() {
+		state.SetNextState(types.GameStateProfile, nil)
+	})
+	group.Add(profile)
+	center.Y += offset
+
 	// Exit
 	exit := ui.NewElement()
 	exit.SetCenter(center)
@@ -278,6 +294,7 @@ func NewTitleState() *Title {
 		settings.SetText(l.String(l.STATE_SETTINGS))
 		howToPlay.SetText(l.String(l.STATE_HOW_TO_PLAY))
 		exit.SetText(l.String(l.EXIT))
+		profile.SetText(profileText())
 		if login != nil {
 			if loginState == external.StateOnline {
 				login.SetText(l.String(l.LOGIN_LOGOUT))
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/liqmix/slaptrax/internal/beats"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/logger"
 	"github.com/liqmix/slaptrax/internal/types/schema"
 )
@@ -12,7 +14,9 @@ type Chart struct {
 	TotalNotes     int
 	TotalHoldNotes int
 	Tracks         []*Track
-	EventManager   *EventManager // Event system for visual/gameplay effects
+	Tempo          *beats.TempoMap // Song bpm with the chart's bpm changes applied
+	Scroll         *ScrollMap      // Note positions with the chart's speed changes applied
+	EventManager   *EventManager   // Event system for visual/gameplay effects
 }
 
 func NewChart(song *Song, data []byte) (*Chart, error) {
@@ -28,6 +32,8 @@ func NewChart(song *Song, data []byte) (*Chart, error) {
 		EventManager: NewEventManager(),
 		TotalNotes:   chartData.NoteCount,
 		TotalHoldNotes: chartData.HoldCount,
+		Tempo:        judge.NewTempoMap(song.BPM, chartData.Events),
+		Scroll:       NewScrollMap(chartData.Events),
 	}
 	
 	chart.Tracks = make([]*Track, 0)
@@ -78,11 +84,11 @@ func NewChart(song *Song, data []byte) (*Chart, error) {
 	}
 	
 	// Create tracks from notes
-	beatInterval := int64((60000.0 / float64(song.BPM)) / 4) // Quarter note interval in ms
 	for _, name := range TrackNames() {
-		track := NewTrack(name, notes[name], beatInterval)
+		track := NewTrack(name, notes[name], chart.Tempo, chart.Scroll)
 		chart.Tracks = append(chart.Tracks, track)
 	}
+	chart.CountNotes()
 	
 	// Load events into the event manager
 	for _, eventData := range chartData.Events {
@@ -104,6 +110,45 @@ func NewChart(song *Song, data []byte) (*Chart, error) {
 	return chart, nil
 }
 
+// CountNotes totals the notes the chart is scored on from its tracks,
+// rather than trusting the counts in the chart file
+func (c *Chart) CountNotes() {
+	c.TotalNotes, c.TotalHoldNotes = 0, 0
+	for _, track := range c.Tracks {
+		for _, n := range track.AllNotes {
+			c.TotalNotes++
+			if n.TargetRelease > 0 {
+				c.TotalHoldNotes++
+			}
+		}
+	}
+}
+
+// GetBeatInterval returns the length of a beat in ms at the given time
+func (c *Chart) GetBeatInterval(at int64) int64 {
+	return c.Tempo.BeatInterval(at)
+}
+
+// GetCountdownTicks returns the count-in ticks, a beat apart at the tempo of the given time
+func (c *Chart) GetCountdownTicks(at int64, restart bool) []int64 {
+	b := c.GetBeatInterval(at)
+
+	if restart {
+		return []int64{
+			-b * 2,
+			-b * 1,
+			0,
+		}
+	}
+	return []int64{
+		-b * 4,
+		-b * 3,
+		-b * 2,
+		-b * 1,
+		0,
+	}
+}
+
 // Helper function to convert string to TrackName
 func stringToTrackName(trackNameStr string) TrackName {
 	switch trackNameStr {
@@ -109,8 +109,8 @@ func NewBPMChangeEvent(time int64, newBPM int) *BPMChangeEvent {
 
 func (e *BPMChangeEvent) Execute(ctx *EventContext) error {
 	e.markExecuted()
-	// TODO: Implement BPM change logic
-	// This would update the audio manager's tempo
+	// Timing is driven by Chart.Tempo, which is built from these events
+	// ahead of time so notes and markers can be placed before the change
 	return nil
 }
 
@@ -185,7 +185,7 @@ func NewSpeedChangeEvent(time int64, multiplier float32) *SpeedChangeEvent {
 
 func (e *SpeedChangeEvent) Execute(ctx *EventContext) error {
 	e.markExecuted()
-	// TODO: Implement speed change logic
+	// Note positions come from Chart.Scroll, which is built from these events
 	return nil
 }
 
@@ -1,7 +1,7 @@
 package types
 
 import (
-	"math"
+	"github.com/liqmix/slaptrax/internal/judge"
 )
 
 type HitRecord struct {
@@ -33,9 +33,9 @@ func (r HitTiming) String() string {
 }
 
 func GetHitTiming(diff int64) HitTiming {
-	if diff > int64(Slap) {
+	if judge.IsEarly(diff) {
 		return HitTimingEarly
-	} else if diff < int64(-Slap) {
+	} else if judge.IsLate(diff) {
 		return HitTimingLate
 	}
 	return HitTimingNone
@@ -62,16 +62,6 @@ func (r HitRating) String() string {
 	return ""
 }
 
-func (r HitRating) Value() float64 {
-	switch r {
-	case Slap:
-		return 1
-	case Slip:
-		return 0.5
-	}
-	return 0
-}
-
 func (r HitRating) Color() GameColor {
 	switch r {
 	case Slap:
@@ -83,36 +73,3 @@ func (r HitRating) Color() GameColor {
 	}
 	return White
 }
-
-// Loosen the window for early hits
-var earlyScale = 0.4
-
-func (r HitRating) Window(early bool) float64 {
-	scale := 1.0
-	if early {
-		scale = earlyScale
-	}
-	switch r {
-	case Slap:
-		return 60
-	case Slip:
-		return 120 * scale
-	}
-	return 0
-}
-
-func GetHitRating(diff int64) HitRating {
-	d := math.Abs(float64(diff))
-
-	early := GetHitTiming(diff) == HitTimingEarly
-	if d < Slap.Window(early) {
-		return Slap
-	} else if d < Slip.Window(early) {
-		return Slip
-	}
-
-	return None
-}
-
-var EarliestWindow = int64(Slip.Window(true))
-var LatestWindow = int64(Slip.Window(false))
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+// Lane positions the sudden and hidden modifiers fade notes over,
+// from the vanishing point (0) to the judgement line (1)
+const (
+	suddenFadeStart = 0.2
+	suddenFadeEnd   = 0.35
+	hiddenFadeStart = 0.5
+	hiddenFadeEnd   = 0.7
+)
+
+// ArrangeTracks returns the tracks of a chart moved to the lanes they're played on,
+// see judge.Options.Lanes. The notes are shared with the chart's tracks.
+func ArrangeTracks(tracks []*Track, opts judge.Options) []*Track {
+	lanes := opts.Lanes()
+	arranged := make([]*Track, 0, len(tracks))
+	for _, t := range tracks {
+		track := *t
+		if lane, ok := lanes[t.Name.SchemaName()]; ok {
+			track.Name = stringToTrackName(lane)
+		}
+		arranged = append(arranged, &track)
+	}
+	return arranged
+}
+
+// ModifierAlpha returns how visible the sudden and hidden modifiers leave a note
+// at a position on its lane, from 0 to 1
+func ModifierAlpha(mods judge.Mods, position float32) float32 {
+	alpha := float32(1)
+	if mods.Has(judge.ModSudden) {
+		alpha *= fade(position, suddenFadeStart, suddenFadeEnd)
+	}
+	if mods.Has(judge.ModHidden) {
+		alpha *= 1 - fade(position, hiddenFadeStart, hiddenFadeEnd)
+	}
+	return alpha
+}
+
+// fade goes from 0 at start to 1 at end
+func fade(position, start, end float32) float32 {
+	return min(max((position-start)/(end-start), 0), 1)
+}
@@ -1,7 +1,10 @@
 package schema
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
+	"sort"
 )
 
 // SongDataV2 represents the complete JSON song format
@@ -219,6 +222,57 @@ func GetTrackNames() []string {
 	}
 }
 
+// Hash returns the song hash used to identify it to the service.
+// Charts and tracks are hashed in a fixed order so the result is stable.
+func (s *SongDataV2) Hash() string {
+	hasher := sha256.New()
+
+	// Hash metadata
+	hasher.Write([]byte(s.Metadata.Title))
+	hasher.Write([]byte(s.Metadata.Artist))
+	hasher.Write([]byte(fmt.Sprintf("%d", s.Metadata.BPM)))
+	hasher.Write([]byte(s.Audio.File))
+
+	charts := make([]ChartDataV2, 0, len(s.Charts))
+	for _, chart := range s.Charts {
+		charts = append(charts, chart)
+	}
+	sort.Slice(charts, func(i, j int) bool {
+		return charts[i].Difficulty < charts[j].Difficulty
+	})
+
+	for _, chart := range charts {
+		hasher.Write([]byte(fmt.Sprintf("diff:%d", chart.Difficulty)))
+		hasher.Write([]byte(fmt.Sprintf("notes:%d", chart.NoteCount)))
+
+		trackNames := make([]string, 0, len(chart.Tracks))
+		for trackName := range chart.Tracks {
+			trackNames = append(trackNames, trackName)
+		}
+		sort.Strings(trackNames)
+
+		for _, trackName := range trackNames {
+			hasher.Write([]byte(trackName))
+			for _, note := range chart.Tracks[trackName] {
+				hasher.Write([]byte(fmt.Sprintf("t:%d:d:%d", note.Time, note.Duration)))
+			}
+		}
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil))
+}
+
+// GetChart returns the chart for a difficulty, if it exists
+func (s *SongDataV2) GetChart(difficulty int) *ChartDataV2 {
+	for _, chart := range s.Charts {
+		if chart.Difficulty == difficulty {
+			c := chart
+			return &c
+		}
+	}
+	return nil
+}
+
 // ToJSON serializes the song data to JSON
 func (s *SongDataV2) ToJSON() ([]byte, error) {
 	return json.MarshalIndent(s, "", "  ")
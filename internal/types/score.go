@@ -1,10 +1,14 @@
 package types
 
-import "image/color"
+import (
+	"image/color"
+
+	"github.com/liqmix/slaptrax/internal/judge"
+)
 
 type SongRating int
 
-const MaxScore = 100000
+const MaxScore = judge.MaxScore
 
 const (
 	RatingSSS SongRating = iota
@@ -98,31 +102,21 @@ type Score struct {
 	Early      int
 	Late       int
 	HitRecords []*HitRecord
-	hitValue   int
+	Replay     *judge.Replay
 
 	// Hold note interval tracking
 	HoldIntervals    int // Total intervals across all holds
 	HoldIntervalsHit int // Successfully held intervals
-	holdIntervalValue int // Points per interval
 }
 
-var score *Score
-
 func NewScore(song *Song, difficulty Difficulty) *Score {
-	chart := song.Charts[difficulty]
-	totalNotes := chart.TotalNotes
-
-	// Hold notes are worth 2× regular notes: initial hit + intervals
-	totalScoreUnits := totalNotes + (chart.TotalHoldNotes * 2)
-	
-	score = &Score{
+	totalNotes := song.Charts[difficulty].TotalNotes
+	return &Score{
 		Song:       song,
 		Difficulty: difficulty,
 		TotalNotes: totalNotes,
 		HitRecords: make([]*HitRecord, 0, totalNotes),
-		hitValue:   MaxScore / totalScoreUnits,
 	}
-	return score
 }
 
 func (s *Score) Reset() {
@@ -144,65 +138,6 @@ func (s *Score) GetLastHitRecord() *HitRecord {
 	return s.HitRecords[len(s.HitRecords)-1]
 }
 
-func AddHit(h *HitRecord) {
-	s := score
-	hitType := h.HitRating
-	if hitType == None || hitType == Slop {
-		return
-	}
-	s.HitRecords = append(s.HitRecords, h)
-	switch hitType {
-	case Slap:
-		s.Slap++
-	case Slip:
-		s.Slip++
-	}
-	s.Combo++
-	if s.Combo > s.MaxCombo {
-		s.MaxCombo = s.Combo
-	}
-
-	timing := h.HitTiming
-	if timing == HitTimingEarly {
-		s.Early++
-	} else if timing == HitTimingLate {
-		s.Late++
-	}
-	s.TotalScore += int(hitType.Value() * float64(s.hitValue))
-}
-
-func (s *Score) AddMiss(n *Note) {
-	record := &HitRecord{
-		Note:      n,
-		HitRating: Slop,
-	}
-	s.HitRecords = append(s.HitRecords, record)
-	s.Slop++
-	s.Combo = 0
-}
-
 func (s *Score) GetAccuracy() float64 {
 	return float64(s.Slap) / float64(s.TotalNotes)
 }
-
-// AddHoldInterval adds scoring for hold note intervals
-func AddHoldInterval(hit bool) {
-	AddHoldIntervalWithCombo(hit, true)
-}
-
-// AddHoldIntervalWithCombo adds scoring for hold note intervals with optional combo breaking
-func AddHoldIntervalWithCombo(hit bool, breakComboOnMiss bool) {
-	s := score
-	s.HoldIntervals++
-	
-	if hit {
-		s.HoldIntervalsHit++
-		// Award interval points - each interval is worth hitValue/intervalCount
-		s.TotalScore += s.holdIntervalValue
-	} else {
-		// Only break combo if this is a definitive miss (not temporary release)
-		if breakComboOnMiss {
-			s.Combo = 0
-		}
-	}
-}
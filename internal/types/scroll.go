@@ -0,0 +1,85 @@
+package types
+
+import (
+	"math"
+	"sort"
+
+	"github.com/liqmix/slaptrax/internal/types/schema"
+)
+
+type scrollSegment struct {
+	time     int64   // ms the segment starts at
+	position float64 // scroll position at the start of the segment
+	speed    float64
+}
+
+// ScrollMap maps song time to a scroll position, the integral of the charted
+// speed multiplier over time. At a constant 1x the position is the time itself.
+type ScrollMap struct {
+	segments []scrollSegment
+}
+
+// NewScrollMap builds the scroll map of a chart from its speed_change events
+func NewScrollMap(events []schema.EventData) *ScrollMap {
+	changes := make([]schema.EventData, 0)
+	for _, e := range events {
+		if e.Type != schema.EventTypeSpeedChange {
+			continue
+		}
+		if _, ok := e.Properties["multiplier"].(float64); ok {
+			changes = append(changes, e)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Time < changes[j].Time
+	})
+
+	m := &ScrollMap{
+		segments: []scrollSegment{{speed: 1}},
+	}
+	for _, c := range changes {
+		last := &m.segments[len(m.segments)-1]
+		time := max(c.Time, 0)
+
+		// Notes can stop, but never scroll backwards
+		speed := math.Max(c.Properties["multiplier"].(float64), 0)
+
+		// Later changes at the same time win
+		if time == last.time {
+			last.speed = speed
+			continue
+		}
+		m.segments = append(m.segments, scrollSegment{
+			time:     time,
+			position: last.position + float64(time-last.time)*last.speed,
+			speed:    speed,
+		})
+	}
+	return m
+}
+
+// PositionAt returns the scroll position at the given time
+func (m *ScrollMap) PositionAt(ms int64) float64 {
+	i := sort.Search(len(m.segments), func(i int) bool {
+		return m.segments[i].time > ms
+	})
+	s := m.segments[max(i-1, 0)]
+	return s.position + float64(ms-s.time)*s.speed
+}
+
+// LatestTimeAt returns the last time the scroll hasn't passed the given position
+func (m *ScrollMap) LatestTimeAt(position float64) int64 {
+	i := sort.Search(len(m.segments), func(i int) bool {
+		return m.segments[i].position > position
+	})
+	s := m.segments[max(i-1, 0)]
+
+	// Stopped for the rest of the song
+	if s.speed == 0 {
+		if i == 0 {
+			return s.time
+		}
+		return math.MaxInt64
+	}
+	return s.time + int64(math.Floor((position-s.position)/s.speed))
+}
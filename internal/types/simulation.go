@@ -0,0 +1,112 @@
+package types
+
+import (
+	"github.com/liqmix/slaptrax/internal/judge"
+)
+
+// Simulation judges a chart's tracks as input comes in, keeping the tracks
+// and score the renderer reads in sync with the judge.Simulation underneath.
+type Simulation struct {
+	sim    *judge.Simulation
+	opts   judge.Options
+	tracks []*Track
+	notes  map[*judge.Note]*Note
+	score  *Score
+	judged int // Judgements already added to the score's hit records
+}
+
+// NewSimulation starts judging the tracks, resetting their notes
+func NewSimulation(tracks []*Track, score *Score, opts judge.Options) (*Simulation, error) {
+	s := &Simulation{
+		opts:   opts,
+		tracks: tracks,
+		notes:  make(map[*judge.Note]*Note),
+		score:  score,
+	}
+
+	for _, t := range tracks {
+		for _, n := range t.AllNotes {
+			s.notes[&n.Note] = n
+		}
+	}
+
+	sim, err := judge.NewSimulation(judgeNotes(tracks), opts)
+	if err != nil {
+		return nil, err
+	}
+	s.sim = sim
+	s.sync()
+	return s, nil
+}
+
+// Autoplay plays the tracks, returning the replay of the play
+func Autoplay(tracks []*Track, opts judge.Options, h judge.Humanize) *judge.Replay {
+	return judge.Autoplay(judgeNotes(tracks), opts, h)
+}
+
+// judgeNotes returns the notes of the tracks keyed by schema track name
+func judgeNotes(tracks []*Track) map[string][]*judge.Note {
+	notes := make(map[string][]*judge.Note)
+	for _, t := range tracks {
+		name := t.Name.SchemaName()
+		for _, n := range t.AllNotes {
+			notes[name] = append(notes[name], &n.Note)
+		}
+	}
+	return notes
+}
+
+// Window returns the first and last time input is recorded at, see judge.Simulation.Window
+func (s *Simulation) Window() (start, end int64) {
+	return s.sim.Window()
+}
+
+// EventLimit returns the most input events a replay can have, see judge.Simulation.EventLimit
+func (s *Simulation) EventLimit() int {
+	return s.sim.EventLimit()
+}
+
+// Input applies a press or release, judged on the next Step
+func (s *Simulation) Input(e judge.InputEvent) error {
+	return s.sim.Input(e)
+}
+
+// Step judges the tracks at the given time
+func (s *Simulation) Step(currentTime int64) {
+	s.sim.Step(currentTime)
+	s.sync()
+}
+
+func (s *Simulation) sync() {
+	for _, t := range s.tracks {
+		t.Active, t.StaleActive = s.sim.Pressed(t.Name.SchemaName())
+	}
+
+	result := s.sim.Score()
+	score := s.score
+	score.TotalNotes = result.TotalNotes
+	score.TotalScore = result.Total()
+	score.Slap = result.Slap
+	score.Slip = result.Slip
+	score.Slop = result.Slop
+	score.Early = result.Early
+	score.Late = result.Late
+	score.Combo = result.Combo
+	score.MaxCombo = result.MaxCombo
+	score.HoldIntervals = result.HoldIntervals
+	score.HoldIntervalsHit = result.HoldIntervalsHit
+
+	for _, j := range result.Judgements[s.judged:] {
+		record := &HitRecord{
+			Note:      s.notes[j.Note],
+			HitRating: HitRating(j.Rating),
+			HitTiming: HitTimingNone,
+		}
+		if j.Rating != judge.Slop {
+			record.HitDiff = j.Diff
+			record.HitTiming = GetHitTiming(j.Diff + s.opts.InputOffset)
+		}
+		score.HitRecords = append(score.HitRecords, record)
+	}
+	s.judged = len(result.Judgements)
+}
@@ -141,26 +141,3 @@ func (s *Song) GetSongLinks() *SongLinks {
 		CharterLink: s.ChartedByLink,
 	}
 }
-
-func (s *Song) GetBeatInterval() int64 {
-	return int64(60000 / s.BPM)
-}
-
-func (s *Song) GetCountdownTicks(restart bool) []int64 {
-	b := s.GetBeatInterval()
-
-	if restart {
-		return []int64{
-			-b * 2,
-			-b * 1,
-			0,
-		}
-	}
-	return []int64{
-		-b * 4,
-		-b * 3,
-		-b * 2,
-		-b * 1,
-		0,
-	}
-}
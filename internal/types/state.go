@@ -19,6 +19,7 @@ const (
 	GameStateDifficultySelection GameState = l.STATE_DIFFICULTY_SELECTION
 	GameStateResult              GameState = l.STATE_RESULT
 	GameStateLogin               GameState = l.STATE_LOGIN
+	GameStateProfile             GameState = l.STATE_PROFILE
 	GameStateModal               GameState = "modal"
 	GameStateBack                GameState = l.BACK
 	GameStateExit                GameState = l.EXIT
@@ -4,6 +4,7 @@ import (
 	"image/color"
 
 	"github.com/liqmix/slaptrax/internal/input"
+	"github.com/liqmix/slaptrax/internal/types/schema"
 	"github.com/liqmix/slaptrax/internal/user"
 )
 
@@ -49,6 +50,25 @@ func (t TrackName) String() string {
 	return "Unknown"
 }
 
+// SchemaName returns the track name used in song.json charts
+func (t TrackName) SchemaName() string {
+	switch t {
+	case TrackLeftBottom:
+		return schema.TrackLeftBottom
+	case TrackLeftTop:
+		return schema.TrackLeftTop
+	case TrackRightBottom:
+		return schema.TrackRightBottom
+	case TrackRightTop:
+		return schema.TrackRightTop
+	case TrackCenterBottom:
+		return schema.TrackCenterBottom
+	case TrackCenterTop:
+		return schema.TrackCenterTop
+	}
+	return ""
+}
+
 func (t TrackName) Action() input.Action {
 	switch t {
 	case TrackLeftBottom:
@@ -3,13 +3,16 @@ package ui
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/audio"
 	"github.com/liqmix/slaptrax/internal/beats"
 	"github.com/liqmix/slaptrax/internal/external"
+	"github.com/liqmix/slaptrax/internal/judge"
 	"github.com/liqmix/slaptrax/internal/l"
 	"github.com/liqmix/slaptrax/internal/types"
+	"github.com/liqmix/slaptrax/internal/user"
 	"github.com/tinne26/etxt"
 )
 
@@ -18,7 +21,9 @@ type Leaderboard struct {
 
 	scores    *UIGroup
 	itemCache map[string]map[int][]*LeaderboardItem
+	judgement string // Profile ID the cached scores were played with
 	title     *Element
+	best      *Element // Local personal best, shown online or not
 
 	loading   bool
 	connected bool
@@ -148,6 +153,11 @@ func NewLeaderboard() *Leaderboard {
 	text.SetTextBold(true)
 	text.SetTextScale(2.5)
 	lb.title = text
+
+	best := NewElement()
+	best.SetDisabled(true)
+	best.SetCenter(Point{X: center.X, Y: 0.3})
+	lb.best = best
 	return lb
 }
 
@@ -183,9 +193,18 @@ func (l *Leaderboard) SetItems(items []*LeaderboardItem) {
 	l.loading = false
 }
 
-func (l *Leaderboard) FetchScores(song string, difficulty int, bpm float64) {
+func (l *Leaderboard) FetchScores(song string, difficulty int, tempo *beats.TempoMap) {
 	l.loading = true
-	l.bmager.SetBPM(bpm)
+	l.bmager.SetTempoMap(tempo)
+
+	// Only scores played with the same judgement are compared
+	profile := user.S().JudgementProfile()
+	judgement := profile.ID()
+	if judgement != l.judgement {
+		l.itemCache = make(map[string]map[int][]*LeaderboardItem)
+		l.judgement = judgement
+	}
+	l.best.SetText(personalBestText(song, difficulty, profile))
 
 	if difficulties, ok := l.itemCache[song]; ok {
 		if scores, ok := difficulties[difficulty]; ok {
@@ -194,7 +213,7 @@ func (l *Leaderboard) FetchScores(song string, difficulty int, bpm float64) {
 		}
 	}
 	go func() {
-		scores, err := external.GetLeaderboard(song, difficulty)
+		scores, err := external.GetLeaderboard(song, difficulty, judgement)
 		if err != nil {
 			l.loading = false
 			l.SetItems([]*LeaderboardItem{})
@@ -204,7 +223,64 @@ func (l *Leaderboard) FetchScores(song string, difficulty int, bpm float64) {
 	}()
 }
 
+func personalBestText(song string, difficulty int, profile judge.Profile) string {
+	best := external.GetBestPlay(song, difficulty, profile.ID())
+	if best == nil {
+		return JudgementText(profile)
+	}
+	return fmt.Sprintf("%s  %s: %d", JudgementText(profile), l.String(l.PERSONAL_BEST), best.Score)
+}
+
+// JudgementText returns the localized name of a judgement profile, custom ones are noted as unranked
+func JudgementText(profile judge.Profile) string {
+	if !profile.Builtin() {
+		return fmt.Sprintf("%s (%s)", l.String(l.JUDGEMENT_CUSTOM), l.String(l.UNRANKED))
+	}
+	switch profile.Name {
+	case judge.Lenient.Name:
+		return l.String(l.JUDGEMENT_LENIENT)
+	case judge.Strict.Name:
+		return l.String(l.JUDGEMENT_STRICT)
+	}
+	return l.String(l.JUDGEMENT_STANDARD)
+}
+
+// ModifierText returns the localized name of a modifier
+func ModifierText(mod judge.Mods) string {
+	switch mod {
+	case judge.ModMirror:
+		return l.String(l.MOD_MIRROR)
+	case judge.ModFlip:
+		return l.String(l.MOD_FLIP)
+	case judge.ModShuffle:
+		return l.String(l.MOD_SHUFFLE)
+	case judge.ModSudden:
+		return l.String(l.MOD_SUDDEN)
+	case judge.ModHidden:
+		return l.String(l.MOD_HIDDEN)
+	case judge.ModAllTaps:
+		return l.String(l.MOD_ALL_TAPS)
+	}
+	return ""
+}
+
+// ModsText lists the localized names of modifiers, noting if they keep the play unranked
+func ModsText(mods judge.Mods) string {
+	names := make([]string, 0, len(judge.Modifiers))
+	for _, mod := range judge.Modifiers {
+		if mods.Has(mod.Mod) {
+			names = append(names, ModifierText(mod.Mod))
+		}
+	}
+	text := strings.Join(names, ", ")
+	if mods.Unranked() {
+		text = fmt.Sprintf("%s (%s)", text, l.String(l.UNRANKED))
+	}
+	return text
+}
+
 func (l *Leaderboard) Update() {
+	l.best.Update()
 	if !l.connected {
 		return
 	}
@@ -220,6 +296,7 @@ func (l *Leaderboard) Update() {
 }
 
 func (l *Leaderboard) Draw(screen *ebiten.Image, opts *ebiten.DrawImageOptions) {
+	l.best.Draw(screen, opts)
 	if !l.connected {
 		return
 	}
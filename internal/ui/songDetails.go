@@ -4,9 +4,11 @@ import (
 	"fmt"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/liqmix/slaptrax/internal/external"
 	"github.com/liqmix/slaptrax/internal/l"
 	"github.com/liqmix/slaptrax/internal/logger"
 	"github.com/liqmix/slaptrax/internal/types"
+	"github.com/liqmix/slaptrax/internal/user"
 )
 
 var (
@@ -27,6 +29,7 @@ type SongDetails struct {
 	bpm     *Element
 	version *Element
 	year    *Element
+	best    *Element // Local personal best of the selected chart
 
 	chartText      *Element
 	difficultyText *Element
@@ -122,6 +125,12 @@ func NewSongDetails() *SongDetails {
 	// center.Y += offset * 2
 	d.charter = b
 
+	e = NewElement()
+	e.SetCenter(center)
+	e.SetSize(size)
+	e.SetTextScale(0.8)
+	d.best = e
+
 	center.Y += offset * 2
 
 	height := center.Y - detailsTop.Y + offset
@@ -169,6 +178,13 @@ func (s *SongDetails) UpdateDetails(song *types.Song, difficulty types.Difficult
 	s.version.SetText(song.Version)
 	s.year.SetText(fmt.Sprintf("%d", song.Year))
 
+	plays := external.GetPlayCount(song.Hash, int(difficulty))
+	if best := external.GetBestPlay(song.Hash, int(difficulty), user.S().JudgementProfile().ID()); best != nil {
+		s.best.SetText(fmt.Sprintf("%s: %d  %s: %d", l.String(l.PERSONAL_BEST), best.Score, l.String(l.PLAYS), plays))
+	} else {
+		s.best.SetText(fmt.Sprintf("%s: %d", l.String(l.PLAYS), plays))
+	}
+
 	difficulties := song.GetDifficulties()
 	s.difficulties = make([]*Element, 0, len(difficulties))
 	spacing := 0.025
@@ -223,6 +239,7 @@ func (s *SongDetails) Draw(screen *ebiten.Image, opts *ebiten.DrawImageOptions)
 	s.bpm.Draw(screen, opts)
 	// s.chartText.Draw(screen, opts)
 	s.version.Draw(screen, opts)
+	s.best.Draw(screen, opts)
 	// s.charter.Draw(screen, opts)
 	// s.difficultyText.Draw(screen, opts)
 	for _, d := range s.difficulties {
@@ -38,6 +38,8 @@ func (t *TextInput) GetText() string { return t.text }
 
 func (t *TextInput) SetIsPassword(p bool) { t.isPassword = p }
 
+func (t *TextInput) SetMaxLength(n int) { t.maxLength = n }
+
 // func (t *TextInput) SetOnChange(f func(string)) { t.onChange = f }
 
 func (t *TextInput) Update() {
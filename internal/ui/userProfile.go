@@ -3,10 +3,12 @@ package ui
 import (
 	"fmt"
 	"image/color"
+	"sync/atomic"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/liqmix/slaptrax/internal/assets"
 	"github.com/liqmix/slaptrax/internal/external"
+	"github.com/liqmix/slaptrax/internal/l"
 	"github.com/liqmix/slaptrax/internal/types"
 	"github.com/liqmix/slaptrax/internal/user"
 	"github.com/tinne26/etxt"
@@ -19,7 +21,8 @@ type UserProfile struct {
 	position Point
 	avatar   *ebiten.Image
 
-	connected bool
+	// Updated from the connectivity monitor, see external.OnLoginStateChange
+	loginState atomic.Int32
 	
 	// Pre-created UI elements to avoid per-frame allocation
 	avatarElement *Element
@@ -47,17 +50,27 @@ func NewUserProfile() *UserProfile {
 	avatarGroup.SetSize(Point{X: avatarSize, Y: avatarSize})
 	avatarGroup.Add(art)
 	
-	return &UserProfile{
+	u := &UserProfile{
 		position:      Point{X: equalMargin, Y: headerCenterY},
 		avatar:        assets.GetImage("default_art.png"), // Use default album art as avatar
-		connected:     external.HasConnection(),
 		avatarElement: art,
 		avatarGroup:   avatarGroup,
 	}
+	u.loginState.Store(int32(external.GetLoginState()))
+	external.OnLoginStateChange(func(state external.LoginState) {
+		u.loginState.Store(int32(state))
+	})
+	return u
+}
+
+// Reconnecting users keep their profile shown
+func (u *UserProfile) isLoggedIn() bool {
+	state := external.LoginState(u.loginState.Load())
+	return state == external.StateOnline || state == external.StateReconnecting
 }
 
 func (u *UserProfile) Update() {
-	if !u.connected {
+	if !u.isLoggedIn() {
 		return
 	}
 
@@ -114,9 +127,11 @@ func (u *UserProfile) Draw(image *ebiten.Image, opts *ebiten.DrawImageOptions) {
 		Y: headerCenterY - 0.03, // Adjusted for smaller text
 	}
 
-	if !u.connected || external.GetLoginState() == external.StateOffline {
+	if !u.isLoggedIn() {
 		// Show "slapGuest" when not logged in
 		DrawTextAt(image, "slapGuest", textCenter, textOpts, opts)
+		textCenter.Y += 0.04
+		u.drawPending(image, textCenter, textOpts, opts)
 		return
 	}
 
@@ -159,5 +174,28 @@ func (u *UserProfile) Draw(image *ebiten.Image, opts *ebiten.DrawImageOptions) {
 			textOpts.Color = types.RankTitleFromRank(u.rank).Color()
 		}
 		DrawTextAt(image, u.title, textCenter, textOpts, opts)
+		textCenter.Y += 0.03
+
+		if external.LoginState(u.loginState.Load()) == external.StateReconnecting {
+			textOpts.Scale = 0.8
+			textOpts.Color = CornerTrackColor()
+			DrawTextAt(image, l.String(l.LOGIN_TEXT_RECONNECTING), textCenter, textOpts, opts)
+			textCenter.Y += 0.03
+		}
+		u.drawPending(image, textCenter, textOpts, opts)
+	}
+}
+
+// drawPending shows how many plays are still waiting to be uploaded,
+// and how many the server rejected
+func (u *UserProfile) drawPending(image *ebiten.Image, center *Point, textOpts *TextOptions, opts *ebiten.DrawImageOptions) {
+	textOpts.Scale = 0.8
+	textOpts.Color = CornerTrackColor()
+	if pending := external.PendingScores(); pending > 0 {
+		DrawTextAt(image, fmt.Sprintf("%s: %d", l.String(l.LOGIN_TEXT_PENDING), pending), center, textOpts, opts)
+		center.Y += 0.03
+	}
+	if rejected := len(external.RejectedScores()); rejected > 0 {
+		DrawTextAt(image, fmt.Sprintf("%s: %d", l.String(l.LOGIN_TEXT_REJECTED), rejected), center, textOpts, opts)
 	}
 }
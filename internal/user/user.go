@@ -7,4 +7,10 @@ var (
 	Init    = external.M.Initialize
 	S       = external.M.GetSettings
 	Save    = external.M.SaveSettings
+
+	// Player profiles, each with their own settings
+	Profile         = external.M.GetPlayerProfile
+	Profiles        = external.M.GetPlayerProfiles
+	SwitchProfile   = external.M.SwitchPlayerProfile
+	OnProfileSwitch = external.M.OnPlayerProfileChange
 )